@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// altRule maps files matching a pattern to their counterparts. Patterns
+// contain a single "*" standing for the stem the two share.
+type altRule struct {
+	from string
+	to   []string
+}
+
+// builtinAlternates are the built-in counterpart rules. The first rule
+// matching a file applies, so more specific patterns come first.
+var builtinAlternates = []altRule{
+	{"*.pb.go", []string{"*.proto"}},
+	{"*_test.go", []string{"*.go"}},
+	{"*.go", []string{"*_test.go"}},
+	{"*.proto", []string{"*.pb.go"}},
+	{"*.c", []string{"*.h"}},
+	{"*.cc", []string{"*.h", "*.hh"}},
+	{"*.cpp", []string{"*.h", "*.hpp"}},
+	{"*.h", []string{"*.c", "*.cc", "*.cpp"}},
+	{"*.hh", []string{"*.cc"}},
+	{"*.hpp", []string{"*.cpp"}},
+	{"*.spec.ts", []string{"*.ts"}},
+	{"*.test.ts", []string{"*.ts"}},
+	{"*.ts", []string{"*.spec.ts", "*.test.ts"}},
+	{"*.spec.tsx", []string{"*.tsx"}},
+	{"*.test.tsx", []string{"*.tsx"}},
+	{"*.tsx", []string{"*.spec.tsx", "*.test.tsx"}},
+	{"*.spec.js", []string{"*.js"}},
+	{"*.test.js", []string{"*.js"}},
+	{"*.js", []string{"*.spec.js", "*.test.js"}},
+	{"*.spec.jsx", []string{"*.jsx"}},
+	{"*.test.jsx", []string{"*.jsx"}},
+	{"*.jsx", []string{"*.spec.jsx", "*.test.jsx"}},
+}
+
+// alternateRules returns the configured rules, longest pattern first,
+// followed by the built-in ones.
+func alternateRules(configured map[string][]string) []altRule {
+	var rules []altRule
+	for from, to := range configured {
+		rules = append(rules, altRule{from, to})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].from) != len(rules[j].from) {
+			return len(rules[i].from) > len(rules[j].from)
+		}
+		return rules[i].from < rules[j].from
+	})
+	return append(rules, builtinAlternates...)
+}
+
+// matchStem reports whether name matches pattern, returning the part of
+// name matched by its "*".
+func matchStem(pattern, name string) (string, bool) {
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok {
+		return "", pattern == name
+	}
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	return name[len(prefix) : len(name)-len(suffix)], true
+}
+
+// alternateNames returns the names of the counterparts of the file name
+// under the first rule that matches it.
+func alternateNames(name string, rules []altRule) []string {
+	for _, r := range rules {
+		stem, ok := matchStem(r.from, name)
+		if !ok {
+			continue
+		}
+		var names []string
+		for _, to := range r.to {
+			names = append(names, strings.Replace(to, "*", stem, 1))
+		}
+		return names
+	}
+	return nil
+}
+
+// findAlternates returns the counterparts of the file at path: those in
+// its directory or, if there are none, those in the first directory below
+// its project root found to have one, looking for each name the rule
+// gives in turn.
+func findAlternates(path string, rules []altRule, cfg *config, opts searchOptions) ([]match, error) {
+	names := alternateNames(filepath.Base(path), rules)
+	if ms := alternatesIn(filepath.Dir(path), names); len(ms) > 0 {
+		return ms, nil
+	}
+	project := findProjectRoot(filepath.Dir(path), cfg.markers)
+	if project == "" {
+		return nil, nil
+	}
+	for _, name := range names {
+		iter, err := newSearchIter([]string{project}, ".../"+name, opts)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := iter.Next()
+		iter.Close()
+		if ok {
+			return alternatesIn(filepath.Dir(m.path), names), nil
+		}
+	}
+	return nil, nil
+}
+
+// alternatesIn returns the files in dir with the given names.
+func alternatesIn(dir string, names []string) []match {
+	var ms []match
+	for _, name := range names {
+		p := filepath.Join(dir, name)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			ms = append(ms, match{path: p})
+		}
+	}
+	return ms
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAlternateNames(t *testing.T) {
+	rules := alternateRules(map[string][]string{
+		"*.rs":       {"*_test.rs"},
+		"*_test.rs":  {"*.rs"},
+		"*.gen.go":   {"*.tmpl"},
+		"handler_*":  {"*_handler"},
+		"*.proto.go": {"*.proto"},
+	})
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"foo.go", []string{"foo_test.go"}},
+		{"foo_test.go", []string{"foo.go"}},
+		{"api.pb.go", []string{"api.proto"}},
+		{"api.proto", []string{"api.pb.go"}},
+		{"x.h", []string{"x.c", "x.cc", "x.cpp"}},
+		{"x.spec.ts", []string{"x.ts"}},
+		{"lib.rs", []string{"lib_test.rs"}},
+		{"lib_test.rs", []string{"lib.rs"}}, // the longer configured pattern wins
+		{"x.gen.go", []string{"x.tmpl"}},    // configured rules before built-in ones
+		{"handler_x", []string{"x_handler"}},
+		{"README", nil},
+		{".go", nil}, // the stem may not be empty
+	}
+	for _, tt := range tests {
+		if got := alternateNames(tt.name, rules); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("alternateNames(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindAlternates(t *testing.T) {
+	tmp := t.TempDir()
+	for _, f := range []string{
+		"p/src/foo.c",
+		"p/src/bar.c",
+		"p/src/bar.h",
+		"p/include/a/foo.h",
+		"p/include/a/foo.cc",
+		"p/include/b/foo.h",
+		"elsewhere/baz.h",
+		"p/src/baz.c",
+	} {
+		writeFile(t, filepath.Join(tmp, f), "")
+	}
+	if err := os.Mkdir(filepath.Join(tmp, "p/.git"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config{markers: []string{".git"}, roots: []string{filepath.Join(tmp, "elsewhere")}}
+	rules := alternateRules(nil)
+	tests := []struct {
+		file string
+		want []string
+	}{
+		// In the same directory.
+		{"p/src/bar.c", []string{"p/src/bar.h"}},
+		// Below the project root, in the first directory with any.
+		{"p/src/foo.c", []string{"p/include/a/foo.h"}},
+		// Names are looked for in the order of the rule.
+		{"p/include/b/foo.h", []string{"p/src/foo.c"}},
+		// Not in other roots.
+		{"p/src/baz.c", nil},
+	}
+	for _, tt := range tests {
+		ms, err := findAlternates(filepath.Join(tmp, tt.file), rules, cfg, searchOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range ms {
+			rel, _ := filepath.Rel(tmp, m.path)
+			got = append(got, rel)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findAlternates(%s) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// projectConfigName is the name of the per-project config file, found by
+// walking up from the current directory.
+const projectConfigName = ".edit.toml"
+
+// config holds the settings that can come from config files, the
+// environment and flags. Later sources take precedence: built-in
+// defaults, then the global config file, the project config file, and
+// finally flags. The editor is the exception: it is the global config's
+// editor if set, or else $EDITOR, and a project config file may not set
+// it, since it is run on the user's behalf. List settings
+// accumulate instead: roots are searched in the order project, $EDITPATH,
+// global, then the modules of the Go workspace (see goWorkspace.roots);
+// ignore and skipfs patterns from all sources apply. Aliases, types and
+// alternates are merged by name, project over global; root names
+// likewise, with $EDITPATH between the two.
+//
+// A config file is a TOML subset:
+//
+//	roots = ["api=~/src/api", "~/src/web"] # relative to the file's directory
+//	ignore = ["node_modules", "*.min.js"]
+//	sort = "mtime"                         # or "name"
+//	editor = "code -w"                     # global config only
+//	follow = "always"                      # see -follow
+//	xdev = true
+//	skipfs = ["nfs", "fuse"]
+//	project = true                         # see -project
+//	markers = ["Cargo.toml"]               # besides .git, go.mod and go.work
+//	columns = ["status", "mtime"]          # see -columns
+//
+//	[aliases]
+//	main = ".../cmd/.../main.go"
+//
+//	[types]
+//	web = ["*.html", "*.css"]
+//
+//	[alternates]                           # see -alt
+//	"*.rs" = ["*_test.rs"]
+type config struct {
+	roots      []string
+	rootNames  map[string]string // name → root, for @name patterns
+	ignore     []string
+	sort       string
+	editor     string
+	follow     string
+	xdev       bool
+	skipFS     []string
+	project    bool     // search from the project root instead of pwd
+	markers    []string // names marking a project root
+	columns    []string // picker columns; see pickerColumns
+	aliases    map[string]string
+	types      map[string][]string
+	alternates map[string][]string // file pattern → counterpart patterns
+	workspace  *goWorkspace        // the Go workspace containing pwd, or nil
+
+	globalFile  string            // path of the global config file, if any
+	projectFile string            // path of the project config file, if any
+	sources     map[string]string // setting → where its value came from
+}
+
+// globalConfigPath returns the path of the global config file in the XDG
+// config directory.
+func globalConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "edit", "config.toml")
+}
+
+// findProjectConfig returns the nearest project config file in dir or its
+// parents, or "" if there is none.
+func findProjectConfig(dir string) string {
+	for {
+		p := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadConfig merges the global and project config files with the
+// environment, for a working directory of pwd.
+func loadConfig(pwd string) (*config, error) {
+	cfg := &config{
+		sort:       "name",
+		follow:     "explicit",
+		markers:    []string{".git", "go.mod", "go.work"},
+		aliases:    make(map[string]string),
+		rootNames:  make(map[string]string),
+		types:      make(map[string][]string),
+		alternates: make(map[string][]string),
+		sources: map[string]string{
+			"sort":    "default",
+			"follow":  "default",
+			"markers": "default",
+		},
+	}
+
+	// $EDITOR is only a default: an editor chosen for edit in the global
+	// config overrides it.
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		cfg.editor = editor
+		cfg.sources["editor"] = "$EDITOR"
+	}
+
+	var globalRoots, projectRoots []namedRoot
+	if p := globalConfigPath(); p != "" {
+		roots, err := cfg.loadFile(p, "global")
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			cfg.globalFile = p
+			globalRoots = roots
+		}
+	}
+	if p := findProjectConfig(pwd); p != "" && p != cfg.globalFile {
+		roots, err := cfg.loadFile(p, "project")
+		if err != nil {
+			return nil, err
+		}
+		cfg.projectFile = p
+		projectRoots = roots
+	}
+
+	var envRoots []namedRoot
+	if editpath := os.Getenv("EDITPATH"); editpath != "" {
+		for _, entry := range strings.Split(editpath, ":") {
+			r := parseRoot(entry)
+			r.dir = expandHome(r.dir)
+			envRoots = append(envRoots, r)
+		}
+	}
+
+	for _, layer := range []struct {
+		roots  []namedRoot
+		source string
+	}{
+		{projectRoots, "project"},
+		{envRoots, "$EDITPATH"},
+		{globalRoots, "global"},
+	} {
+		for _, r := range layer.roots {
+			cfg.roots = append(cfg.roots, r.dir)
+			if _, ok := cfg.rootNames[r.name]; r.name != "" && !ok {
+				cfg.rootNames[r.name] = r.dir
+			}
+		}
+		if len(layer.roots) > 0 {
+			cfg.addSource("roots", layer.source)
+		}
+	}
+	// The modules of a Go workspace are searched after the other roots.
+	if cfg.workspace = findGoWorkspace(pwd); cfg.workspace != nil {
+		if roots := cfg.workspace.roots(); len(roots) > 0 {
+			cfg.roots = append(cfg.roots, roots...)
+			cfg.addSource("roots", "go workspace")
+		}
+	}
+	return cfg, nil
+}
+
+// loadFile applies the config file at path, returning its roots, which
+// are kept apart because they are ordered differently from other
+// settings.
+func (cfg *config) loadFile(path, source string) ([]namedRoot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseConfig(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", path, err)
+	}
+
+	var roots []namedRoot
+	dir := filepath.Dir(path)
+	for _, kv := range values {
+		bad := func() error {
+			return fmt.Errorf("%s:%d: invalid value for %s", path, kv.line, kv.key)
+		}
+		table, key, _ := strings.Cut(kv.key, ".")
+		if key == "" {
+			table, key = "", table
+		}
+		switch table {
+		case "aliases":
+			s, ok := kv.value.(string)
+			if !ok {
+				return nil, bad()
+			}
+			cfg.aliases[key] = s
+			cfg.sources["aliases."+key] = source
+			continue
+		case "types", "alternates":
+			rules, ok := kv.value.([]string)
+			if !ok {
+				return nil, bad()
+			}
+			if table == "types" {
+				cfg.types[key] = rules
+			} else {
+				cfg.alternates[key] = rules
+			}
+			cfg.sources[table+"."+key] = source
+			continue
+		case "":
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown table [%s]", path, kv.line, table)
+		}
+
+		switch key {
+		case "roots":
+			list, ok := kv.value.([]string)
+			if !ok {
+				return nil, bad()
+			}
+			for _, entry := range list {
+				r := parseRoot(entry)
+				r.dir = expandHome(r.dir)
+				if !filepath.IsAbs(r.dir) {
+					r.dir = filepath.Join(dir, r.dir)
+				}
+				roots = append(roots, r)
+			}
+		case "columns":
+			list, ok := kv.value.([]string)
+			if !ok {
+				return nil, bad()
+			}
+			if err := cfg.setColumns(list, source); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, kv.line, err)
+			}
+		case "ignore", "skipfs", "markers":
+			list, ok := kv.value.([]string)
+			if !ok {
+				return nil, bad()
+			}
+			switch key {
+			case "ignore":
+				cfg.ignore = append(cfg.ignore, list...)
+			case "skipfs":
+				cfg.skipFS = append(cfg.skipFS, list...)
+			case "markers":
+				cfg.markers = append(cfg.markers, list...)
+			}
+			cfg.addSource(key, source)
+		case "sort", "editor", "follow":
+			if key == "editor" && source != "global" {
+				return nil, fmt.Errorf("%s:%d: editor can only be set in the global config", path, kv.line)
+			}
+			s, ok := kv.value.(string)
+			if !ok {
+				return nil, bad()
+			}
+			if err := cfg.set(key, s, source); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, kv.line, err)
+			}
+		case "xdev", "project":
+			b, ok := kv.value.(bool)
+			if !ok {
+				return nil, bad()
+			}
+			if key == "xdev" {
+				cfg.xdev = b
+			} else {
+				cfg.project = b
+			}
+			cfg.sources[key] = source
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown setting %s", path, kv.line, key)
+		}
+	}
+	return roots, nil
+}
+
+// set sets a string-valued setting, validating it.
+func (cfg *config) set(key, value, source string) error {
+	switch key {
+	case "sort":
+		if value != "name" && value != "mtime" {
+			return fmt.Errorf("invalid sort %q (want name or mtime)", value)
+		}
+		cfg.sort = value
+	case "editor":
+		cfg.editor = value
+	case "follow":
+		if _, err := parseSymlinkPolicy(value); err != nil {
+			return err
+		}
+		cfg.follow = value
+	}
+	cfg.sources[key] = source
+	return nil
+}
+
+// setColumns sets the picker columns, validating them. Unlike other list
+// settings, columns don't accumulate: the last source chooses them all.
+func (cfg *config) setColumns(columns []string, source string) error {
+	for _, c := range columns {
+		if !slices.Contains(pickerColumns, c) {
+			return fmt.Errorf("invalid column %q (want %s)", c, strings.Join(pickerColumns, ", "))
+		}
+	}
+	cfg.columns = columns
+	cfg.sources["columns"] = source
+	return nil
+}
+
+// addSource records that source contributed to a list setting.
+func (cfg *config) addSource(key, source string) {
+	if s := cfg.sources[key]; s != "" {
+		source = s + ", " + source
+	}
+	cfg.sources[key] = source
+}
+
+// settingFlags holds the command-line flags that override settings, nil
+// for those not given.
+type settingFlags struct {
+	mtime   *bool   // -m
+	follow  *string // -follow
+	xdev    *bool   // -xdev
+	project *bool   // -project
+	skipFS  *string // -skipfs
+	columns *string // -columns
+}
+
+// applyFlags overrides settings with the flags given on the command line.
+func (cfg *config) applyFlags(f settingFlags) error {
+	if f.mtime != nil {
+		sort := "name"
+		if *f.mtime {
+			sort = "mtime"
+		}
+		if err := cfg.set("sort", sort, "flag"); err != nil {
+			return err
+		}
+	}
+	if f.follow != nil {
+		if err := cfg.set("follow", *f.follow, "flag"); err != nil {
+			return err
+		}
+	}
+	if f.xdev != nil {
+		cfg.xdev = *f.xdev
+		cfg.sources["xdev"] = "flag"
+	}
+	if f.project != nil {
+		cfg.project = *f.project
+		cfg.sources["project"] = "flag"
+	}
+	if f.skipFS != nil {
+		cfg.skipFS = append(cfg.skipFS, splitList(*f.skipFS)...)
+		cfg.addSource("skipfs", "flag")
+	}
+	if f.columns != nil {
+		return cfg.setColumns(splitList(*f.columns), "flag")
+	}
+	return nil
+}
+
+// dump writes the effective configuration to w in config file syntax,
+// noting where each setting came from.
+func (cfg *config) dump(w io.Writer) {
+	file := func(p string) string {
+		if p == "" {
+			return "none"
+		}
+		return p
+	}
+	fmt.Fprintf(w, "# global config:  %s\n", file(cfg.globalFile))
+	fmt.Fprintf(w, "# project config: %s\n", file(cfg.projectFile))
+
+	line := func(key, value string) {
+		if src := cfg.sources[key]; src != "" {
+			fmt.Fprintf(w, "%-40s # %s\n", value, src)
+		} else {
+			fmt.Fprintln(w, value)
+		}
+	}
+	k := func(key string, value any) {
+		line(key, key+" = "+formatConfigValue(value))
+	}
+	names := make(map[string]string)
+	for name, dir := range cfg.rootNames {
+		names[dir] = name
+	}
+	var roots []string
+	for _, dir := range cfg.roots {
+		if name := names[dir]; name != "" {
+			dir = name + "=" + dir
+		}
+		roots = append(roots, dir)
+	}
+	k("roots", roots)
+	k("ignore", cfg.ignore)
+	k("sort", cfg.sort)
+	k("editor", cfg.editor)
+	k("follow", cfg.follow)
+	k("xdev", cfg.xdev)
+	k("project", cfg.project)
+	k("markers", cfg.markers)
+	k("skipfs", cfg.skipFS)
+	k("columns", cfg.columns)
+
+	if len(cfg.aliases) > 0 {
+		fmt.Fprintf(w, "\n[aliases]\n")
+		for _, name := range sortedKeys(cfg.aliases) {
+			line("aliases."+name, formatConfigKey(name)+" = "+formatConfigValue(cfg.aliases[name]))
+		}
+	}
+	if len(cfg.types) > 0 {
+		fmt.Fprintf(w, "\n[types]\n")
+		for _, name := range sortedKeys(cfg.types) {
+			line("types."+name, formatConfigKey(name)+" = "+formatConfigValue(cfg.types[name]))
+		}
+	}
+	if len(cfg.alternates) > 0 {
+		fmt.Fprintf(w, "\n[alternates]\n")
+		for _, pattern := range sortedKeys(cfg.alternates) {
+			line("alternates."+pattern, formatConfigKey(pattern)+" = "+formatConfigValue(cfg.alternates[pattern]))
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatConfigKey(key string) string {
+	if strings.Trim(key, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-") == "" {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+func formatConfigValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		q := make([]string, len(v))
+		for i, s := range v {
+			q[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(q, ", ") + "]"
+	}
+	return fmt.Sprint(v)
+}
+
+// expandHome replaces a leading "~" or "~/" in path with the home
+// directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + path[1:]
+}
+
+// configValue is a key = value pair read from a config file. Keys in
+// tables are qualified by the table name ("aliases.main").
+type configValue struct {
+	key   string
+	value any // string, bool, int64 or []string
+	line  int
+}
+
+// configParser parses the subset of TOML used by config files:
+// comments, [table] headers, and key = value pairs whose values are
+// strings, booleans, integers or arrays of strings, which may span lines.
+type configParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func parseConfig(s string) ([]configValue, error) {
+	p := &configParser{s: s, line: 1}
+	var values []configValue
+	table := ""
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.s) {
+			return values, nil
+		}
+		if p.s[p.pos] == '[' {
+			p.pos++
+			p.skipSpace(false)
+			name, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.consume(']') {
+				return nil, p.errorf("expected ]")
+			}
+			table = name
+		} else {
+			line := p.line
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.consume('=') {
+				return nil, p.errorf("expected = after %s", key)
+			}
+			p.skipSpace(false)
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if table != "" {
+				key = table + "." + key
+			}
+			values = append(values, configValue{key, v, line})
+		}
+		p.skipSpace(false)
+		if p.pos < len(p.s) && p.s[p.pos] != '\n' {
+			return nil, p.errorf("unexpected %q", p.s[p.pos])
+		}
+	}
+}
+
+func (p *configParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *configParser) consume(c byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// skipSpace skips blanks and comments, and newlines if newlines is set.
+func (p *configParser) skipSpace(newlines bool) {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		default:
+			return
+		}
+	}
+}
+
+// key parses a bare or quoted key.
+func (p *configParser) key() (string, error) {
+	if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		return p.str()
+	}
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if !(c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.errorf("expected key")
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *configParser) value() (any, error) {
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("expected value")
+	}
+	switch c := p.s[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '[':
+		p.pos++
+		var list []string
+		for {
+			p.skipSpace(true)
+			if p.consume(']') {
+				return list, nil
+			}
+			s, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, s)
+			p.skipSpace(true)
+			if !p.consume(',') {
+				p.skipSpace(true)
+				if !p.consume(']') {
+					return nil, p.errorf("expected , or ] in array")
+				}
+				return list, nil
+			}
+		}
+	case strings.HasPrefix(p.s[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.s[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		n, err := strconv.ParseInt(p.s[start:p.pos], 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.s[start:p.pos])
+		}
+		return n, nil
+	}
+	return nil, p.errorf("invalid value")
+}
+
+// str parses a basic ("...", with escapes) or literal ('...') string.
+func (p *configParser) str() (string, error) {
+	if p.pos >= len(p.s) {
+		return "", p.errorf("expected string")
+	}
+	quote := p.s[p.pos]
+	if quote != '"' && quote != '\'' {
+		return "", p.errorf("expected string")
+	}
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.s) && p.s[p.pos] != quote && p.s[p.pos] != '\n' {
+		if quote == '"' && p.s[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if !p.consume(quote) {
+		return "", p.errorf("unterminated string")
+	}
+	if quote == '\'' {
+		return p.s[start+1 : p.pos-1], nil
+	}
+	s, err := strconv.Unquote(p.s[start:p.pos])
+	if err != nil {
+		return "", p.errorf("invalid string %s", p.s[start:p.pos])
+	}
+	return s, nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []configValue
+	}{
+		{
+			name: "scalars",
+			in:   "sort = \"mtime\"\nxdev = true\nproject=false\nn = -12\n",
+			want: []configValue{
+				{"sort", "mtime", 1},
+				{"xdev", true, 2},
+				{"project", false, 3},
+				{"n", int64(-12), 4},
+			},
+		},
+		{
+			name: "escapes",
+			in:   `a = "tab\there \"q\" \\ \u00e9"` + "\n" + `b = 'C:\raw\path'`,
+			want: []configValue{
+				{"a", "tab\there \"q\" \\ é", 1},
+				{"b", `C:\raw\path`, 2},
+			},
+		},
+		{
+			name: "comments and blank lines",
+			in:   "# header\n\n  editor = \"vi\" # trailing\n\n",
+			want: []configValue{{"editor", "vi", 3}},
+		},
+		{
+			name: "arrays",
+			in:   "a = []\nb = [\"x\"]\nc = [\"x\", 'y',]\nd = [\n  \"x\", # one\n  \"y\"\n]\ne = 1\n",
+			want: []configValue{
+				{"a", []string(nil), 1},
+				{"b", []string{"x"}, 2},
+				{"c", []string{"x", "y"}, 3},
+				{"d", []string{"x", "y"}, 4},
+				{"e", int64(1), 8},
+			},
+		},
+		{
+			name: "tables",
+			in:   "roots = [\"api=~/src/api\"]\n[aliases]\nmain = \".../main.go\"\n\"a b\" = \"x\"\n[ types ]\nweb = [\"*.html\"]\n",
+			want: []configValue{
+				{"roots", []string{"api=~/src/api"}, 1},
+				{"aliases.main", ".../main.go", 3},
+				{"aliases.a b", "x", 4},
+				{"types.web", []string{"*.html"}, 6},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(tt.in)
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseConfig =\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"sort", "1: expected = after sort"},
+		{"a = \"x\"\nb = \"unterminated\n", "2: unterminated string"},
+		{"a = \"x\"\n\nb = nope\n", "3: invalid value"},
+		{"a = [\"x\" \"y\"]", "1: expected , or ] in array"},
+		{"a = [1]", "1: expected string"},
+		{"a = \"x\" b", "1: unexpected 'b'"},
+		{"[aliases\n", "1: expected ]"},
+		{"a = \"\\q\"", "1: invalid string"},
+		{"= 1", "1: expected key"},
+	}
+	for _, tt := range tests {
+		_, err := parseConfig(tt.in)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("parseConfig(%q) error = %v, want %q", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		in     string
+		want   string
+	}{
+		{"global", "sort = true\n", ":1: invalid value for sort"},
+		{"global", "\nsort = \"size\"\n", `:2: invalid sort "size"`},
+		{"global", "\n\nroots = \"dir\"\n", ":3: invalid value for roots"},
+		{"global", "bogus = 1\n", ":1: unknown setting bogus"},
+		{"global", "[aliases]\nmain = [\"x\"]\n", ":2: invalid value for aliases.main"},
+		{"global", "[types]\nweb = \"*.html\"\n", ":2: invalid value for types.web"},
+		{"global", "\n[roots]\na = \"x\"\n", ":3: unknown table [roots]"},
+		{"global", "columns = [\"status\", \"owner\"]\n", `:1: invalid column "owner"`},
+		{"project", "\neditor = \"rm -rf\"\n", ":2: editor can only be set in the global config"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "config.toml")
+		if err := os.WriteFile(path, []byte(tt.in), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		cfg := newTestConfig()
+		_, err := cfg.loadFile(path, tt.source)
+		if err == nil || !strings.HasPrefix(err.Error(), path+tt.want) {
+			t.Errorf("loadFile(%q) error = %v, want %s", tt.in, err, path+tt.want)
+		}
+	}
+}
+
+func TestLoadFileRoots(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", "/home/u")
+	path := filepath.Join(dir, "config.toml")
+	in := `roots = ["api=~/src/api", "rel", "/abs", "a/b=x"]`
+	if err := os.WriteFile(path, []byte(in), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	roots, err := newTestConfig().loadFile(path, "global")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []namedRoot{
+		{"api", "/home/u/src/api"},
+		{"", filepath.Join(dir, "rel")},
+		{"", "/abs"},
+		{"", filepath.Join(dir, "a/b=x")}, // names can't contain "/"
+	}
+	if !reflect.DeepEqual(roots, want) {
+		t.Errorf("roots = %v, want %v", roots, want)
+	}
+}
+
+// TestLoadConfigLayers checks the precedence of the global config, the
+// project config, the environment and flags.
+func TestLoadConfigLayers(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "xdg/edit/config.toml"), `
+roots = ["g=groot"]
+ignore = ["*.o"]
+sort = "mtime"
+editor = "vi"
+follow = "always"
+skipfs = ["nfs"]
+columns = ["size"]
+
+[aliases]
+main = "global"
+gonly = "g"
+
+[types]
+web = ["*.html"]
+`)
+	writeFile(t, filepath.Join(tmp, "proj", projectConfigName), `
+roots = ["p=proot", "shared=proot2"]
+ignore = ["node_modules"]
+sort = "name"
+columns = ["status", "root"]
+
+[aliases]
+main = "project"
+`)
+	pwd := filepath.Join(tmp, "proj", "sub")
+	if err := os.MkdirAll(pwd, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmp, "xdg"))
+	t.Setenv("EDITPATH", "e=/edir:/e2:shared=/eshared")
+	t.Setenv("EDITOR", "nano")
+
+	cfg, err := loadConfig(pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRoots := []string{
+		filepath.Join(tmp, "proj/proot"),
+		filepath.Join(tmp, "proj/proot2"),
+		"/edir",
+		"/e2",
+		"/eshared",
+		filepath.Join(tmp, "xdg/edit/groot"),
+	}
+	if !reflect.DeepEqual(cfg.roots, wantRoots) {
+		t.Errorf("roots = %v, want %v", cfg.roots, wantRoots)
+	}
+	wantNames := map[string]string{
+		"p":      filepath.Join(tmp, "proj/proot"),
+		"shared": filepath.Join(tmp, "proj/proot2"), // project over $EDITPATH
+		"e":      "/edir",
+		"g":      filepath.Join(tmp, "xdg/edit/groot"),
+	}
+	if !reflect.DeepEqual(cfg.rootNames, wantNames) {
+		t.Errorf("rootNames = %v, want %v", cfg.rootNames, wantNames)
+	}
+	check := func(what string, got, want any) {
+		t.Helper()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", what, got, want)
+		}
+	}
+	check("ignore", cfg.ignore, []string{"*.o", "node_modules"})
+	check("sort", cfg.sort, "name")
+	check("sort source", cfg.sources["sort"], "project")
+	check("editor", cfg.editor, "vi") // the global config over $EDITOR
+	check("editor source", cfg.sources["editor"], "global")
+	check("follow", cfg.follow, "always")
+	check("columns", cfg.columns, []string{"status", "root"})
+	check("aliases", cfg.aliases, map[string]string{"main": "project", "gonly": "g"})
+	check("types", cfg.types, map[string][]string{"web": {"*.html"}})
+
+	mtime, skipFS, columns := true, "fuse", "mtime"
+	err = cfg.applyFlags(settingFlags{mtime: &mtime, skipFS: &skipFS, columns: &columns})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("sort", cfg.sort, "mtime")
+	check("sort source", cfg.sources["sort"], "flag")
+	check("follow", cfg.follow, "always") // not given
+	check("skipfs", cfg.skipFS, []string{"nfs", "fuse"})
+	check("skipfs source", cfg.sources["skipfs"], "global, flag")
+	check("columns", cfg.columns, []string{"mtime"})
+
+	bad := "sometimes"
+	if err := cfg.applyFlags(settingFlags{follow: &bad}); err == nil {
+		t.Errorf("applyFlags accepted -follow %s", bad)
+	}
+}
+
+func TestLoadConfigEditorFromEnvironment(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("EDITPATH", "")
+	t.Setenv("EDITOR", "nano -w")
+	cfg, err := loadConfig(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.editor != "nano -w" || cfg.sources["editor"] != "$EDITOR" {
+		t.Errorf("editor = %q from %q, want $EDITOR", cfg.editor, cfg.sources["editor"])
+	}
+}
+
+// newTestConfig returns a config with the maps loadFile fills in.
+func newTestConfig() *config {
+	return &config{
+		aliases:    make(map[string]string),
+		rootNames:  make(map[string]string),
+		types:      make(map[string][]string),
+		alternates: make(map[string][]string),
+		sources:    make(map[string]string),
+	}
+}
+
+// writeFile writes content to path, creating its directory.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// unmergedFiles returns the files with unmerged entries in the index of
+// the repository containing dir, as absolute paths.
+func unmergedFiles(dir string) ([]string, error) {
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := git(top, "ls-files", "-z", "--unmerged")
+	if err != nil {
+		return nil, err
+	}
+	// Each stage of a file is listed: mode object stage<TAB>path.
+	var files []string
+	seen := make(map[string]bool)
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		_, rel, ok := bytes.Cut(entry, []byte{'\t'})
+		if !ok || seen[string(rel)] {
+			continue
+		}
+		seen[string(rel)] = true
+		files = append(files, filepath.Join(top, filepath.FromSlash(string(rel))))
+	}
+	return files, nil
+}
+
+// conflictCandidates returns the files that may contain conflict markers
+// when git lists no unmerged ones, in case a conflict was marked resolved
+// with markers left in: those with unstaged changes and untracked ones.
+func conflictCandidates(dir string) ([]string, error) {
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, args := range [][]string{
+		{"diff", "-z", "--name-only"},
+		{"ls-files", "-z", "--others", "--exclude-standard"},
+	} {
+		out, err := git(top, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range bytes.Split(out, []byte{0}) {
+			if len(rel) > 0 {
+				files = append(files, filepath.Join(top, filepath.FromSlash(string(rel))))
+			}
+		}
+	}
+	return files, nil
+}
+
+// conflictHunks returns a result for each conflict in the file at path,
+// at the line of its <<<<<<< marker and described by the sides' labels.
+func conflictHunks(path string) ([]match, error) {
+	if !isText(path) {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hunks []match
+	start := -1 // index in hunks of the hunk awaiting its >>>>>>> marker
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if label, ok := conflictMarker(line, '<'); ok {
+			hunks = append(hunks, match{path: path, line: n, text: label})
+			start = len(hunks) - 1
+		} else if label, ok := conflictMarker(line, '>'); ok && start >= 0 {
+			hunks[start].text += " ↔ " + label
+			start = -1
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i := range hunks {
+		hunks[i].text = fmt.Sprintf("conflict %d/%d: %s", i+1, len(hunks), hunks[i].text)
+	}
+	return hunks, nil
+}
+
+// conflictMarker reports whether line is a conflict marker of seven c
+// characters, returning the label following it.
+func conflictMarker(line string, c byte) (string, bool) {
+	marker := strings.Repeat(string(c), 7)
+	rest, ok := strings.CutPrefix(line, marker)
+	if !ok || rest != "" && rest[0] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// findConflicts returns every conflict hunk in the files git lists as
+// unmerged in the repository containing dir or, if there are none, in
+// the files conflictCandidates returns. Unmerged files without markers
+// are returned as a whole.
+func findConflicts(dir string) ([]match, error) {
+	files, err := unmergedFiles(dir)
+	unmerged := err == nil && len(files) > 0
+	if !unmerged {
+		files, err = conflictCandidates(dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var ms []match
+	for _, file := range files {
+		hunks, err := conflictHunks(file)
+		if err != nil {
+			continue
+		}
+		// An unmerged file may have no markers, as in a binary file or a
+		// conflict between a change and a deletion.
+		if len(hunks) == 0 && unmerged && isFile(file) {
+			hunks = []match{{path: file, text: "unmerged, no conflict markers"}}
+		}
+		ms = append(ms, hunks...)
+	}
+	return ms, nil
+}
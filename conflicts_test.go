@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConflictMarker(t *testing.T) {
+	tests := []struct {
+		line  string
+		c     byte
+		label string
+		ok    bool
+	}{
+		{"<<<<<<< HEAD", '<', "HEAD", true},
+		{"<<<<<<<", '<', "", true},
+		{">>>>>>> feature/x", '>', "feature/x", true},
+		{"<<<<<<<< HEAD", '<', "", false},
+		{"<<<<<< HEAD", '<', "", false},
+		{"<<<<<<< HEAD", '>', "", false},
+		{" <<<<<<< HEAD", '<', "", false},
+	}
+	for _, tt := range tests {
+		label, ok := conflictMarker(tt.line, tt.c)
+		if label != tt.label || ok != tt.ok {
+			t.Errorf("conflictMarker(%q, %q) = %q, %v, want %q, %v", tt.line, tt.c, label, ok, tt.label, tt.ok)
+		}
+	}
+}
+
+func TestConflictHunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.go")
+	writeFile(t, path, `package f
+<<<<<<< HEAD
+a
+=======
+b
+>>>>>>> topic
+x
+<<<<<<< ours
+||||||| base
+=======
+>>>>>>> theirs
+<<<<<<< unterminated
+`)
+	want := []match{
+		{path: path, line: 2, text: "conflict 1/3: HEAD ↔ topic"},
+		{path: path, line: 8, text: "conflict 2/3: ours ↔ theirs"},
+		{path: path, line: 12, text: "conflict 3/3: unterminated"},
+	}
+	got, err := conflictHunks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("conflictHunks =\n\t%v\nwant\n\t%v", got, want)
+	}
+
+	bin := filepath.Join(t.TempDir(), "bin")
+	writeFile(t, bin, "<<<<<<< HEAD\n\x00")
+	if got, err := conflictHunks(bin); err != nil || got != nil {
+		t.Errorf("conflictHunks(binary) = %v, %v, want none", got, err)
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	repo := initTestRepo(t, "clean.go", "changed.go", "sub/left.go")
+	marked := "<<<<<<< HEAD\na\n=======\nb\n>>>>>>> topic\n"
+	writeFile(t, filepath.Join(repo, "changed.go"), marked)
+	writeFile(t, filepath.Join(repo, "sub/new.go"), marked)
+	// Markers in an unchanged file aren't looked for.
+	if err := os.WriteFile(filepath.Join(repo, "clean.go"), []byte(marked), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := git(repo, "update-index", "--assume-unchanged", "clean.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	ms, err := findConflicts(filepath.Join(repo, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range ms {
+		rel, _ := filepath.Rel(repo, m.path)
+		got = append(got, rel)
+	}
+	want := []string{"changed.go", "sub/new.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findConflicts = %q, want %q", got, want)
+	}
+
+	if _, err := findConflicts(t.TempDir()); err == nil {
+		t.Errorf("findConflicts outside a repository: no error")
+	}
+}
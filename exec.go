@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// execPlaceholders lists the placeholders expanded in -x and -X commands,
+// longest first so that "{//}" is not taken for "{/}".
+var execPlaceholders = []string{"{rel}", "{//}", "{/.}", "{/}", "{.}", "{}"}
+
+// expandPlaceholder returns the value of a placeholder for m:
+//
+//	{}     path
+//	{/}    base name
+//	{//}   parent directory
+//	{.}    path without extension
+//	{/.}   base name without extension
+//	{rel}  path relative to the root it was found under
+func expandPlaceholder(ph string, m match) string {
+	switch ph {
+	case "{/}":
+		return filepath.Base(m.path)
+	case "{//}":
+		return filepath.Dir(m.path)
+	case "{.}":
+		return strings.TrimSuffix(m.path, filepath.Ext(m.path))
+	case "{/.}":
+		base := filepath.Base(m.path)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	case "{rel}":
+		if m.root != "" {
+			if rel, err := filepath.Rel(m.root, m.path); err == nil {
+				return rel
+			}
+		}
+	}
+	return m.path
+}
+
+// expandCommand substitutes the placeholders in command with the
+// shell-quoted values for ms, space-separated when there are several.
+// A command without placeholders gets " {}" appended.
+func expandCommand(command string, ms []match) string {
+	if !hasPlaceholder(command) {
+		command += " {}"
+	}
+	var b strings.Builder
+	for i := 0; i < len(command); {
+		ph := placeholderAt(command[i:])
+		if ph == "" {
+			b.WriteByte(command[i])
+			i++
+			continue
+		}
+		for j, m := range ms {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(shellQuote(expandPlaceholder(ph, m)))
+		}
+		i += len(ph)
+	}
+	return b.String()
+}
+
+func hasPlaceholder(command string) bool {
+	for i := range command {
+		if placeholderAt(command[i:]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderAt returns the placeholder s starts with, or "".
+func placeholderAt(s string) string {
+	for _, ph := range execPlaceholders {
+		if strings.HasPrefix(s, ph) {
+			return ph
+		}
+	}
+	return ""
+}
+
+// shellQuote quotes s for sh, leaving it bare if that is safe.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./+=:,@%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// execJob is one run of a command, with its output held back so that
+// output from concurrent runs is not interleaved.
+type execJob struct {
+	command        string
+	stdout, stderr bytes.Buffer
+	err            error
+	done           chan struct{}
+}
+
+func (j *execJob) run() {
+	defer close(j.done)
+	cmd := exec.Command("sh", "-c", j.command)
+	cmd.Stdout = &j.stdout
+	cmd.Stderr = &j.stderr
+	j.err = cmd.Run()
+	if _, ok := j.err.(*exec.ExitError); !ok && j.err != nil {
+		fmt.Fprintf(&j.stderr, "edit: %v\n", j.err)
+	}
+}
+
+// runExec runs command once per result from iter, at most jobs at a
+// time, and returns the process exit status: non-zero if there were no
+// results or any run failed. Each run's output is written in one piece,
+// in the order of the results.
+func runExec(iter *searchIter, command string, jobs int) int {
+	if jobs < 1 {
+		jobs = 1
+	}
+	sem := make(chan struct{}, jobs)
+	ordered := make(chan *execJob, jobs)
+	failed := false
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for j := range ordered {
+			<-j.done
+			os.Stdout.Write(j.stdout.Bytes())
+			os.Stderr.Write(j.stderr.Bytes())
+			if j.err != nil {
+				failed = true
+			}
+		}
+	}()
+
+	found := false
+	for {
+		m, ok := iter.Next()
+		if !ok {
+			break
+		}
+		found = true
+		j := &execJob{command: expandCommand(command, []match{m}), done: make(chan struct{})}
+		sem <- struct{}{}
+		ordered <- j
+		go func() {
+			defer func() { <-sem }()
+			j.run()
+		}()
+	}
+	close(ordered)
+	<-printed
+
+	if !found {
+		fmt.Fprintln(os.Stderr, "no matches")
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// runExecBatch runs command once with all results from iter and returns
+// its exit status.
+func runExecBatch(iter *searchIter, command string) int {
+	var ms []match
+	for {
+		m, ok := iter.Next()
+		if !ok {
+			break
+		}
+		ms = append(ms, m)
+	}
+	if len(ms) == 0 {
+		fmt.Fprintln(os.Stderr, "no matches")
+		return 1
+	}
+	cmd := exec.Command("sh", "-c", expandCommand(command, ms))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return exit.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		return 1
+	}
+	return 0
+}
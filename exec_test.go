@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestExpandCommand(t *testing.T) {
+	a := match{path: "/src/pkg/a.test.go", root: "/src"}
+	b := match{path: "/src/it's here.go", root: "/src"}
+	tests := []struct {
+		command string
+		ms      []match
+		want    string
+	}{
+		{"gofmt -l", []match{a}, "gofmt -l /src/pkg/a.test.go"},
+		{"wc {}", []match{a, b}, `wc /src/pkg/a.test.go '/src/it'\''s here.go'`},
+		{"echo {/} {//} {.} {/.} {rel}", []match{a}, "echo a.test.go /src/pkg /src/pkg/a.test a.test pkg/a.test.go"},
+		{"mv {} {.}.bak", []match{b}, `mv '/src/it'\''s here.go' '/src/it'\''s here'.bak`},
+		{"echo {rel}", []match{{path: "/x/y.go"}}, "echo /x/y.go"},
+		{"echo {x} {/", []match{a}, "echo {x} {/ /src/pkg/a.test.go"},
+	}
+	for _, tt := range tests {
+		if got := expandCommand(tt.command, tt.ms); got != tt.want {
+			t.Errorf("expandCommand(%q) =\n\t%s\nwant\n\t%s", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a/b.go", "a/b.go"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
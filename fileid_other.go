@@ -0,0 +1,21 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileKey identifies a file independently of the path used to reach it.
+type fileKey struct {
+	dev, ino uint64
+}
+
+// fileKeyOf reports false: files cannot be identified on this platform,
+// so symlink cycles are not detected and results are not deduplicated.
+func fileKeyOf(info os.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
+
+// fileOwner reports false: ownership is not known on this platform.
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file independently of the path used to reach it.
+type fileKey struct {
+	dev, ino uint64
+}
+
+// fileKeyOf returns the device and inode of the file described by info.
+func fileKeyOf(info os.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{uint64(st.Dev), uint64(st.Ino)}, true
+}
+
+// fileOwner returns the user id owning the file described by info.
+func fileOwner(info os.FileInfo) (int, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(st.Uid), true
+}
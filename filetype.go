@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileTypes maps a type name, as used with -t, to the rules recognizing
+// it. A rule is a glob matched against the file name ("*.go",
+// "Makefile") or "#!" followed by an interpreter, matched against the
+// shebang line of files without an extension ("#!python" matches both
+// "#!/usr/bin/python3" and "#!/usr/bin/env python").
+var fileTypes = map[string][]string{
+	"bazel":    {"BUILD", "BUILD.bazel", "WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel", "*.bzl", "*.bazel"},
+	"c":        {"*.c", "*.h"},
+	"cpp":      {"*.cc", "*.cpp", "*.cxx", "*.c++", "*.h", "*.hh", "*.hpp", "*.hxx", "*.inl"},
+	"css":      {"*.css", "*.scss", "*.sass", "*.less"},
+	"docker":   {"Dockerfile", "Dockerfile.*", "*.dockerfile", "Containerfile"},
+	"go":       {"*.go"},
+	"gomod":    {"go.mod", "go.sum", "go.work", "go.work.sum"},
+	"html":     {"*.html", "*.htm"},
+	"java":     {"*.java"},
+	"js":       {"*.js", "*.mjs", "*.cjs", "*.jsx", "#!node"},
+	"json":     {"*.json", "*.jsonl"},
+	"kotlin":   {"*.kt", "*.kts"},
+	"lua":      {"*.lua", "#!lua"},
+	"make":     {"Makefile", "GNUmakefile", "makefile", "*.mk", "*.mak"},
+	"markdown": {"*.md", "*.markdown"},
+	"nix":      {"*.nix"},
+	"perl":     {"*.pl", "*.pm", "*.t", "#!perl"},
+	"proto":    {"*.proto"},
+	"py":       {"*.py", "*.pyi", "#!python"},
+	"rb":       {"*.rb", "Gemfile", "Rakefile", "#!ruby"},
+	"rust":     {"*.rs"},
+	"sh":       {"*.sh", "*.bash", "*.zsh", "#!sh", "#!bash", "#!zsh", "#!dash", "#!ksh"},
+	"sql":      {"*.sql"},
+	"swift":    {"*.swift"},
+	"tf":       {"*.tf", "*.tfvars"},
+	"toml":     {"*.toml"},
+	"ts":       {"*.ts", "*.tsx", "*.mts", "*.cts", "#!ts-node", "#!deno"},
+	"yaml":     {"*.yaml", "*.yml"},
+}
+
+// addFileType extends the type table from a spec of the form
+// "name:rule,rule,...", adding to the rules of an existing type.
+func addFileType(spec string) error {
+	name, rules, ok := strings.Cut(spec, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid type definition %q (want name:rule,...)", spec)
+	}
+	for _, r := range splitList(rules) {
+		if !strings.HasPrefix(r, "#!") {
+			if _, err := filepath.Match(r, ""); err != nil {
+				return fmt.Errorf("invalid type definition %q: bad glob %q", spec, r)
+			}
+		}
+		fileTypes[name] = append(fileTypes[name], r)
+	}
+	return nil
+}
+
+// typePredicate returns a predicate matching files of any of the named
+// types, by name or shebang line only.
+func typePredicate(names []string) (filePredicate, error) {
+	var globs, interps []string
+	for _, name := range names {
+		rules, ok := fileTypes[name]
+		if !ok {
+			return filePredicate{}, fmt.Errorf("unknown file type %q (see -t list)", name)
+		}
+		for _, r := range rules {
+			if interp, ok := strings.CutPrefix(r, "#!"); ok {
+				interps = append(interps, interp)
+			} else {
+				globs = append(globs, r)
+			}
+		}
+	}
+	return pathPredicate(func(path string) bool {
+		base := filepath.Base(path)
+		for _, g := range globs {
+			if ok, _ := filepath.Match(g, base); ok {
+				return true
+			}
+		}
+		if len(interps) == 0 || strings.Contains(base, ".") {
+			return false
+		}
+		interp := shebang(path)
+		for _, want := range interps {
+			if matchInterp(interp, want) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// shebang returns the base name of the interpreter named on the "#!"
+// line of the file at path, looking through "env", or "" if there is
+// none.
+func shebang(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(io.LimitReader(f, 256)).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return ""
+	}
+	rest, ok := strings.CutPrefix(line, "#!")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				return filepath.Base(f)
+			}
+		}
+		return ""
+	}
+	return interp
+}
+
+// matchInterp reports whether interp is want, possibly followed by a
+// version: "python3.12" matches "python".
+func matchInterp(interp, want string) bool {
+	v, ok := strings.CutPrefix(interp, want)
+	return ok && strings.Trim(v, "0123456789.") == ""
+}
+
+// listFileTypes writes the type table to w, sorted by name.
+func listFileTypes(w io.Writer) {
+	names := make([]string, 0, len(fileTypes))
+	for name := range fileTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s: %s\n", name, strings.Join(fileTypes[name], ", "))
+	}
+}
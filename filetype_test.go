@@ -0,0 +1,121 @@
+package main
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchInterp(t *testing.T) {
+	tests := []struct {
+		interp, want string
+		ok           bool
+	}{
+		{"python", "python", true},
+		{"python3", "python", true},
+		{"python3.12", "python", true},
+		{"pythonw", "python", false},
+		{"sh", "sh", true},
+		{"bash", "sh", false},
+		{"", "sh", false},
+	}
+	for _, tt := range tests {
+		if got := matchInterp(tt.interp, tt.want); got != tt.ok {
+			t.Errorf("matchInterp(%q, %q) = %v, want %v", tt.interp, tt.want, got, tt.ok)
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		content, want string
+	}{
+		{"#!/bin/sh\necho\n", "sh"},
+		{"#! /usr/bin/python3 -u\n", "python3"},
+		{"#!/usr/bin/env python3\n", "python3"},
+		{"#!/usr/bin/env -S node --harmony\n", "node"},
+		{"#!/usr/bin/env\n", ""},
+		{"#!\n", ""},
+		{"echo\n", ""},
+		{"", ""},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, string(rune('a'+i)))
+		writeFile(t, path, tt.content)
+		if got := shebang(path); got != tt.want {
+			t.Errorf("shebang(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestTypePredicate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":    "package main\n",
+		"Makefile":   "all:\n",
+		"rules.mk":   "x := 1\n",
+		"tool":       "#!/usr/bin/env python3\n",
+		"run":        "#!/bin/bash\n",
+		"script.txt": "#!/bin/sh\n", // has an extension: no shebang check
+		"data":       "plain\n",
+	}
+	for name, content := range files {
+		writeFile(t, filepath.Join(dir, name), content)
+	}
+	tests := []struct {
+		types []string
+		want  []string
+	}{
+		{[]string{"go"}, []string{"main.go"}},
+		{[]string{"make"}, []string{"Makefile", "rules.mk"}},
+		{[]string{"py"}, []string{"tool"}},
+		{[]string{"sh"}, []string{"run"}},
+		{[]string{"go", "py"}, []string{"main.go", "tool"}},
+	}
+	for _, tt := range tests {
+		pred, err := typePredicate(tt.types)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, name := range sortedKeys(files) {
+			if pred.test(filepath.Join(dir, name), nil) {
+				got = append(got, name)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("-t %v = %q, want %q", tt.types, got, tt.want)
+		}
+	}
+	if _, err := typePredicate([]string{"cobol"}); err == nil {
+		t.Errorf("typePredicate(cobol): no error")
+	}
+}
+
+func TestAddFileType(t *testing.T) {
+	saved := maps.Clone(fileTypes)
+	defer func() { fileTypes = saved }()
+
+	for _, spec := range []string{"web:*.vue,*.svelte", "go:*.go.tmpl", "deno:#!deno"} {
+		if err := addFileType(spec); err != nil {
+			t.Fatalf("addFileType(%q): %v", spec, err)
+		}
+	}
+	want := map[string][]string{
+		"web":  {"*.vue", "*.svelte"},
+		"go":   {"*.go", "*.go.tmpl"},
+		"deno": {"#!deno"},
+	}
+	for name, rules := range want {
+		if !slices.Equal(fileTypes[name], rules) {
+			t.Errorf("fileTypes[%q] = %q, want %q", name, fileTypes[name], rules)
+		}
+	}
+	for _, spec := range []string{"web", ":*.x", "bad:[x"} {
+		if err := addFileType(spec); err == nil {
+			t.Errorf("addFileType(%q): no error", spec)
+		}
+	}
+}
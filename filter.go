@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filePredicate is a condition on a candidate file, evaluated before the
+// file is emitted. Only predicates that need it get the file's
+// symlink-resolved info; others get nil, and spare the walker a stat.
+type filePredicate struct {
+	test     func(path string, info os.FileInfo) bool
+	needInfo bool
+}
+
+// infoPredicate returns a predicate that needs the file's info.
+func infoPredicate(test func(path string, info os.FileInfo) bool) filePredicate {
+	return filePredicate{test: test, needInfo: true}
+}
+
+// pathPredicate returns a predicate on the file's path and contents only.
+func pathPredicate(test func(path string) bool) filePredicate {
+	return filePredicate{test: func(path string, _ os.FileInfo) bool { return test(path) }}
+}
+
+// parsePredicates parses a comma-separated list of file predicates, all
+// of which must hold:
+//
+//	mtime<DUR   modified within DUR (e.g. 90m, 1h, 2d, 1w)
+//	mtime>DUR   modified longer than DUR ago
+//	today       modified since midnight
+//	size>N      larger than N bytes (e.g. 512, 10k, 2M, 1G)
+//	size<N      smaller than N bytes
+//	exec        executable
+//	text        no NUL bytes in the first 8000 bytes
+//	binary      not text
+//	mine        owned by the current user
+//
+// Any predicate may be negated with a leading "!".
+func parsePredicates(s string) ([]filePredicate, error) {
+	var preds []filePredicate
+	for _, expr := range splitList(s) {
+		p, err := parsePredicate(expr)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, p)
+	}
+	return preds, nil
+}
+
+func parsePredicate(expr string) (filePredicate, error) {
+	if rest, ok := strings.CutPrefix(expr, "!"); ok {
+		p, err := parsePredicate(rest)
+		if err != nil {
+			return filePredicate{}, err
+		}
+		return filePredicate{
+			test:     func(path string, info os.FileInfo) bool { return !p.test(path, info) },
+			needInfo: p.needInfo,
+		}, nil
+	}
+
+	switch expr {
+	case "today":
+		y, m, d := time.Now().Date()
+		midnight := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+		return infoPredicate(func(_ string, info os.FileInfo) bool {
+			return !info.ModTime().Before(midnight)
+		}), nil
+	case "exec":
+		return infoPredicate(func(_ string, info os.FileInfo) bool {
+			return info.Mode().Perm()&0111 != 0
+		}), nil
+	case "text":
+		return pathPredicate(isText), nil
+	case "binary":
+		return pathPredicate(func(path string) bool { return !isText(path) }), nil
+	case "mine":
+		uid := os.Getuid()
+		return infoPredicate(func(_ string, info os.FileInfo) bool {
+			owner, ok := fileOwner(info)
+			return ok && owner == uid
+		}), nil
+	}
+
+	i := strings.IndexAny(expr, "<>")
+	if i < 0 {
+		return filePredicate{}, fmt.Errorf("invalid predicate %q", expr)
+	}
+	attr, op, val := expr[:i], expr[i], expr[i+1:]
+	switch attr {
+	case "mtime":
+		d, err := parseAge(val)
+		if err != nil {
+			return filePredicate{}, fmt.Errorf("invalid predicate %q: %v", expr, err)
+		}
+		cutoff := time.Now().Add(-d)
+		if op == '<' {
+			return infoPredicate(func(_ string, info os.FileInfo) bool { return info.ModTime().After(cutoff) }), nil
+		}
+		return infoPredicate(func(_ string, info os.FileInfo) bool { return info.ModTime().Before(cutoff) }), nil
+	case "size":
+		n, err := parseSize(val)
+		if err != nil {
+			return filePredicate{}, fmt.Errorf("invalid predicate %q: %v", expr, err)
+		}
+		if op == '<' {
+			return infoPredicate(func(_ string, info os.FileInfo) bool { return info.Size() < n }), nil
+		}
+		return infoPredicate(func(_ string, info os.FileInfo) bool { return info.Size() > n }), nil
+	}
+	return filePredicate{}, fmt.Errorf("invalid predicate %q: unknown attribute %q", expr, attr)
+}
+
+// parseAge parses a duration, additionally accepting days ("2d") and
+// weeks ("1w"), which time.ParseDuration does not.
+func parseAge(s string) (time.Duration, error) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		return time.ParseDuration(s)
+	}
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// parseSize parses a byte count with an optional k, M or G (binary) suffix.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult != 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// isText reports whether the file at path looks like text: like git, it
+// checks for a NUL byte in the first 8000 bytes. Unreadable files are
+// not text.
+func isText(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, 8000)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	return bytes.IndexByte(buf[:n], 0) < 0
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"90m", 90 * time.Minute, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"2d", 48 * time.Hour, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"1w", 7 * 24 * time.Hour, true},
+		{"d", 0, false},
+		{"3x", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseAge(%q) = %v, %v, want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"512", 512, true},
+		{"10k", 10 << 10, true},
+		{"10K", 10 << 10, true},
+		{"2M", 2 << 20, true},
+		{"1G", 1 << 30, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"1.5M", 0, false},
+		{"k", 0, false},
+		{"10m", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseSize(tt.in)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParsePredicates(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		name    string
+		content string
+		mode    os.FileMode
+		age     time.Duration
+	}{
+		{"new.txt", "hello\n", 0o644, time.Minute},
+		{"old.txt", "hello\n", 0o644, 30 * 24 * time.Hour},
+		{"big.bin", string(make([]byte, 4096)), 0o644, time.Minute},
+		{"run.sh", "#!/bin/sh\n", 0o755, time.Minute},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, f.mode); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-f.age)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"mtime<1d", []string{"big.bin", "new.txt", "run.sh"}},
+		{"mtime>1w", []string{"old.txt"}},
+		{"size>1k", []string{"big.bin"}},
+		{"size<1k,text", []string{"new.txt", "old.txt", "run.sh"}},
+		{"binary", []string{"big.bin"}},
+		{"exec", []string{"run.sh"}},
+		{"!exec,mtime<1d", []string{"big.bin", "new.txt"}},
+		{"mine", []string{"big.bin", "new.txt", "old.txt", "run.sh"}},
+	}
+	for _, tt := range tests {
+		preds, err := parsePredicates(tt.where)
+		if err != nil {
+			t.Fatalf("parsePredicates(%q): %v", tt.where, err)
+		}
+		var got []string
+		for _, name := range []string{"big.bin", "new.txt", "old.txt", "run.sh"} {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok := true
+			for _, p := range preds {
+				ok = ok && p.test(path, info)
+			}
+			if ok {
+				got = append(got, name)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("-where %s = %q, want %q", tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestParsePredicatesErrors(t *testing.T) {
+	for _, where := range []string{"fresh", "mtime<soon", "size>big", "owner<1", "!", "mtime"} {
+		if _, err := parsePredicates(where); err == nil {
+			t.Errorf("parsePredicates(%q): no error", where)
+		}
+	}
+}
+
+func TestPredicateNeedInfo(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"mtime<1d", true},
+		{"size>1k", true},
+		{"exec", true},
+		{"mine", true},
+		{"today", true},
+		{"text", false},
+		{"!binary", false},
+		{"!exec", true},
+	}
+	for _, tt := range tests {
+		p, err := parsePredicate(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.needInfo != tt.want {
+			t.Errorf("parsePredicate(%q).needInfo = %v, want %v", tt.expr, p.needInfo, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "syscall"
+
+// fsType returns the type of the filesystem containing dir, or "" if it
+// cannot be determined.
+func fsType(dir string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return ""
+	}
+	b := make([]byte, 0, len(st.Fstypename))
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
+
+// mountType reports false: there is no mount table to consult, so mount
+// points are only recognized by statting them.
+func mountType(dir string) (string, bool) {
+	return "", false
+}
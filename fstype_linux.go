@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// fsMagic maps statfs(2) f_type values to filesystem type names, as
+// they appear in /proc/self/mountinfo.
+var fsMagic = map[uint32]string{
+	0x0187:     "autofs",
+	0x00c36400: "ceph",
+	0x27e0eb:   "cgroup",
+	0x63677270: "cgroup2",
+	0xff534d42: "cifs",
+	0x64626720: "debugfs",
+	0x1cd1:     "devpts",
+	0xef53:     "ext4",
+	0x65735546: "fuse",
+	0x9660:     "iso9660",
+	0x6969:     "nfs",
+	0x794c7630: "overlay",
+	0x9fa0:     "proc",
+	0x858458f6: "ramfs",
+	0x517b:     "smb",
+	0xfe534d42: "smb3",
+	0x73717368: "squashfs",
+	0x62656572: "sysfs",
+	0x01021994: "tmpfs",
+	0x01021997: "9p",
+	0x4d44:     "vfat",
+	0x9123683e: "btrfs",
+	0x58465342: "xfs",
+	0x2fc12fc1: "zfs",
+}
+
+// fsType returns the type of the filesystem containing dir, or "" if it
+// cannot be determined. FUSE filesystems all share one magic number, so
+// their subtype ("fuse.sshfs") comes from the mount table if dir is a
+// mount point.
+func fsType(dir string) string {
+	if t, ok := mountType(dir); ok {
+		return t
+	}
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return ""
+	}
+	return fsMagic[uint32(st.Type)]
+}
+
+var (
+	mountsOnce sync.Once
+	mounts     map[string]string // mount point → filesystem type
+)
+
+// mountType looks up dir in the mount table without touching dir itself,
+// returning its filesystem type if dir is a mount point.
+func mountType(dir string) (string, bool) {
+	mountsOnce.Do(readMounts)
+	t, ok := mounts[dir]
+	return t, ok
+}
+
+// readMounts reads /proc/self/mountinfo. Each line has the form
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+//
+// where the fifth field is the mount point and the first field after the
+// "-" separator is the type.
+func readMounts() {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	mounts = make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		for i := 5; i < len(fields)-1; i++ {
+			if fields[i] == "-" {
+				mounts[unescapeMount(fields[4])] = fields[i+1]
+				break
+			}
+		}
+	}
+}
+
+// unescapeMount decodes the octal escapes (\040 for space) used for
+// special characters in mountinfo paths.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
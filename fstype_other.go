@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+// fsType returns "": filesystem types are not known on this platform.
+func fsType(dir string) string {
+	return ""
+}
+
+// mountType reports false: there is no mount table to consult.
+func mountType(dir string) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitSources selects which files git lists as candidates, instead of
+// walking the filesystem.
+type gitSources struct {
+	tracked   bool   // files in the index
+	modified  bool   // files with staged or unstaged changes
+	untracked bool   // files not in the index and not ignored
+	diffBase  string // if set, files changed since the merge base with this revision
+}
+
+func (s gitSources) any() bool {
+	return s.tracked || s.modified || s.untracked || s.diffBase != ""
+}
+
+// git runs git in dir and returns its standard output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+// gitTopLevel returns the top-level directory of the work tree containing
+// dir.
+func gitTopLevel(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitFiles returns the files the sources select in the repository
+// containing dir, as NUL-separated absolute paths, and the repository's
+// top-level directory. A file may be listed more than once.
+func gitFiles(dir string, src gitSources) (string, []byte, error) {
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var cmds [][]string
+	if src.tracked {
+		cmds = append(cmds, []string{"ls-files", "-z"})
+	}
+	if src.modified {
+		cmds = append(cmds,
+			[]string{"ls-files", "-z", "--modified"},
+			[]string{"diff", "-z", "--name-only", "--cached"})
+	}
+	if src.untracked {
+		cmds = append(cmds, []string{"ls-files", "-z", "--others", "--exclude-standard"})
+	}
+	if src.diffBase != "" {
+		base, err := git(top, "merge-base", src.diffBase, "HEAD")
+		if err != nil {
+			return "", nil, err
+		}
+		// Against the working tree, so uncommitted changes count too.
+		cmds = append(cmds, []string{"diff", "-z", "--name-only", strings.TrimSpace(string(base))})
+	}
+
+	var paths bytes.Buffer
+	for _, args := range cmds {
+		out, err := git(top, args...)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, rel := range bytes.Split(out, []byte{0}) {
+			if len(rel) == 0 {
+				continue
+			}
+			paths.WriteString(filepath.Join(top, filepath.FromSlash(string(rel))))
+			paths.WriteByte(0)
+		}
+	}
+	return top, paths.Bytes(), nil
+}
+
+// revisionRE matches what may be a revision after the "@" of a pattern: a
+// ref name or commit hash, followed by ~n and ^n suffixes.
+var revisionRE = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_./-]*(?:[~^][0-9]*)*$`)
+
+// fileExtRE matches what ends a file name rather than a revision, as in
+// icon@2x.png.
+var fileExtRE = regexp.MustCompile(`\.[A-Za-z][A-Za-z0-9]*$`)
+
+// splitRevision splits a pattern of the form pattern@revision, as in
+// foo.go@HEAD~3, if dir is in a git work tree and the part after the last
+// "@" looks like a revision and names a commit there.
+func splitRevision(pattern, dir string) (string, string, bool) {
+	i := strings.LastIndex(pattern, "@")
+	if i <= 0 {
+		return "", "", false
+	}
+	rev := pattern[i+1:]
+	if !revisionRE.MatchString(rev) || fileExtRE.MatchString(rev) || strings.Contains(rev, "..") {
+		return "", "", false
+	}
+	if _, err := gitTopLevel(dir); err != nil {
+		return "", "", false
+	}
+	if _, err := git(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}"); err != nil {
+		return "", "", false
+	}
+	return pattern[:i], rev, true
+}
+
+// revisionMatches returns the files in the tree at revision rev of the
+// repository containing dir that match pattern, as paths relative to the
+// top-level directory, which is also returned. A relative pattern is
+// matched below dir's place in the tree first and, if nothing matches
+// there, below the top.
+func revisionMatches(dir, rev, pattern string) (string, []string, error) {
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	out, err := git(top, "ls-tree", "-r", "-z", "--name-only", rev)
+	if err != nil {
+		return "", nil, err
+	}
+	var bases []string
+	if filepath.IsAbs(pattern) {
+		root, rest := splitPattern(pattern)
+		rel, err := filepath.Rel(top, root)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", nil, fmt.Errorf("%s is not in the repository at %s", pattern, top)
+		}
+		bases, pattern = []string{rel}, rest
+	} else {
+		rel, err := filepath.Rel(top, dir)
+		if err != nil {
+			return "", nil, err
+		}
+		bases = []string{rel}
+		if rel != "." {
+			bases = append(bases, ".")
+		}
+	}
+	segs, err := parsePattern(strings.TrimPrefix(pattern, "./"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	files := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for _, base := range bases {
+		prefix := ""
+		if base != "." {
+			prefix = filepath.ToSlash(base) + "/"
+		}
+		var ms []string
+		for _, f := range files {
+			rest, ok := strings.CutPrefix(f, prefix)
+			if ok && matchPath(segs, strings.Split(rest, "/")) {
+				ms = append(ms, f)
+			}
+		}
+		if len(ms) > 0 {
+			return top, ms, nil
+		}
+	}
+	return top, nil, nil
+}
+
+// revisionFileAge is how long copies of files at revisions are kept in
+// case a run of edit didn't get to remove them.
+const revisionFileAge = 24 * time.Hour
+
+// revisionTempDir returns the user's edit-<uid> temporary directory for
+// copies of files at revisions, creating it if needed, after removing the
+// copies in it older than revisionFileAge.
+func revisionTempDir() (string, error) {
+	parent := filepath.Join(os.TempDir(), fmt.Sprintf("edit-%d", os.Getuid()))
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return "", err
+	}
+	entries, _ := os.ReadDir(parent)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > revisionFileAge {
+			os.RemoveAll(filepath.Join(parent, e.Name()))
+		}
+	}
+	return parent, nil
+}
+
+// writeRevisionFile writes the file at path rel in revision rev of the
+// repository at top to a read-only file of the same name in a new
+// directory below revisionTempDir, and returns the file's path. The
+// caller removes the directory.
+func writeRevisionFile(top, rev, rel string) (string, error) {
+	data, err := git(top, "cat-file", "blob", rev+":"+rel)
+	if err != nil {
+		return "", err
+	}
+	// The directory is named after the revision, so that it shows in the
+	// editor; characters such as "/" and "~" don't belong in a name.
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '~' || r == '^' || r == ':' {
+			return '_'
+		}
+		return r
+	}, rev)
+	parent, err := revisionTempDir()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(parent, name+"-")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, filepath.Base(filepath.FromSlash(rel)))
+	if err := os.WriteFile(path, data, 0o444); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return path, nil
+}
+
+// gitStatusCache gives the git status of files, running git status once
+// for each repository they are in, in the background.
+type gitStatusCache struct {
+	mu     sync.Mutex
+	tops   map[string]string          // directory → top level of its work tree, or ""
+	status map[string]map[string]byte // top level → path → status letter; nil while loading
+	ready  chan struct{}              // signalled when a repository's status has loaded
+}
+
+func newGitStatusCache() *gitStatusCache {
+	return &gitStatusCache{
+		tops:   make(map[string]string),
+		status: make(map[string]map[string]byte),
+		ready:  make(chan struct{}, 1),
+	}
+}
+
+// load starts loading the status of the repository containing path, if
+// it isn't loaded or loading already.
+func (c *gitStatusCache) load(path string) {
+	dir := filepath.Dir(path)
+	c.mu.Lock()
+	top, ok := c.tops[dir]
+	c.mu.Unlock()
+	if !ok {
+		top = findUp(dir, ".git")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tops[dir] = top
+	if _, ok := c.status[top]; ok || top == "" {
+		return
+	}
+	c.status[top] = nil
+	go func() {
+		status := gitStatus(top)
+		c.mu.Lock()
+		c.status[top] = status
+		c.mu.Unlock()
+		select {
+		case c.ready <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+// letter returns the status letter of the file at path, as in the first
+// column of git status --short that isn't blank ('M', 'A', '?', ...), or
+// ' ' if it is unchanged, not in a repository or not loaded yet.
+func (c *gitStatusCache) letter(path string) byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l, ok := c.status[c.tops[filepath.Dir(path)]][path]; ok {
+		return l
+	}
+	return ' '
+}
+
+// gitStatus returns the status letters of the changed and untracked files
+// in the work tree at top, by absolute path.
+func gitStatus(top string) map[string]byte {
+	out, err := git(top, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return make(map[string]byte)
+	}
+	return parseGitStatus(top, out)
+}
+
+// parseGitStatus parses the output of git status --porcelain -z run in
+// the work tree at top.
+func parseGitStatus(top string, out []byte) map[string]byte {
+	status := make(map[string]byte)
+	// Entries are "XY path"; renames and copies are followed by the
+	// original path as a separate entry.
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		x, y := e[0], e[1]
+		l := x
+		if l == ' ' {
+			l = y
+		}
+		status[filepath.Join(top, filepath.FromSlash(e[3:]))] = l
+		if x == 'R' || x == 'C' {
+			i++
+		}
+	}
+	return status
+}
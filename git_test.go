@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// initTestRepo creates a git repository in a temporary directory with one
+// commit of the given files, and returns its directory.
+func initTestRepo(t *testing.T, files ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	for _, f := range files {
+		writeFile(t, filepath.Join(dir, f), f+"\n")
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init"},
+		{"tag", "v1.2.3"},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestSplitRevision(t *testing.T) {
+	repo := initTestRepo(t, "a.go", "icon@2x.png")
+	tests := []struct {
+		pattern       string
+		want, wantRev string
+		ok            bool
+	}{
+		{"a.go@HEAD", "a.go", "HEAD", true},
+		{"a.go@HEAD~0", "a.go", "HEAD~0", true},
+		{"...go@v1.2.3", "...go", "v1.2.3", true},
+		{"a.go@x@v1.2.3", "a.go@x", "v1.2.3", true},
+		{"icon@2x.png", "", "", false},
+		{"a.go@nosuchref", "", "", false},
+		{"a.go@HEAD...", "", "", false},
+		{"a.go@", "", "", false},
+		{"@HEAD", "", "", false},
+		{"a.go", "", "", false},
+	}
+	for _, tt := range tests {
+		got, rev, ok := splitRevision(tt.pattern, repo)
+		if got != tt.want || rev != tt.wantRev || ok != tt.ok {
+			t.Errorf("splitRevision(%q) = %q, %q, %v, want %q, %q, %v", tt.pattern, got, rev, ok, tt.want, tt.wantRev, tt.ok)
+		}
+	}
+	if _, _, ok := splitRevision("a.go@HEAD", t.TempDir()); ok {
+		t.Errorf("splitRevision outside a work tree = true, want false")
+	}
+}
+
+func TestWriteRevisionFile(t *testing.T) {
+	repo := initTestRepo(t, "sub/a.go")
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	parent := filepath.Join(tmp, fmt.Sprintf("edit-%d", os.Getuid()))
+	old := filepath.Join(parent, "HEAD-old")
+	recent := filepath.Join(parent, "HEAD-recent")
+	for _, dir := range []string{old, recent} {
+		writeFile(t, filepath.Join(dir, "a.go"), "")
+	}
+	stale := time.Now().Add(-2 * revisionFileAge)
+	if err := os.Chtimes(old, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := writeRevisionFile(repo, "HEAD~0", "sub/a.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Dir(filepath.Dir(path)); got != parent {
+		t.Errorf("copy in %s, want in %s", got, parent)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "sub/a.go\n" {
+		t.Errorf("copy = %q, %v, want the committed file", data, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("stale copy not removed")
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent copy removed: %v", err)
+	}
+}
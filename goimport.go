@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// goEnv holds the parts of the Go environment that locate package
+// sources.
+type goEnv struct {
+	goroot   string
+	gopath   []string
+	modcache string
+}
+
+// loadGoEnv asks the go command for its environment, falling back to the
+// process environment and the go command's defaults if it isn't
+// installed.
+func loadGoEnv() goEnv {
+	var env goEnv
+	out, err := exec.Command("go", "env", "GOROOT", "GOPATH", "GOMODCACHE").Output()
+	if lines := strings.Split(string(out), "\n"); err == nil && len(lines) >= 3 {
+		env.goroot = lines[0]
+		env.gopath = filepath.SplitList(lines[1])
+		env.modcache = lines[2]
+		return env
+	}
+	env.goroot = os.Getenv("GOROOT")
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			gopath = filepath.Join(home, "go")
+		}
+	}
+	env.gopath = filepath.SplitList(gopath)
+	env.modcache = os.Getenv("GOMODCACHE")
+	if env.modcache == "" && len(env.gopath) > 0 {
+		env.modcache = filepath.Join(env.gopath[0], "pkg", "mod")
+	}
+	return env
+}
+
+// goModFile is the part of a go.mod file needed to resolve import paths.
+type goModFile struct {
+	dir     string            // directory containing the go.mod file
+	module  string            // module path
+	require map[string]string // module path → version
+	replace map[string]string // module path → local directory replacing it
+}
+
+// parseGoMod reads the module path and requirements from the go.mod file
+// in dir.
+func parseGoMod(dir string) (*goModFile, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	mod := &goModFile{
+		dir:     dir,
+		require: make(map[string]string),
+		replace: make(map[string]string),
+	}
+	forEachGoModDirective(data, func(verb string, args []string) {
+		switch {
+		case verb == "module" && len(args) >= 1:
+			mod.module = args[0]
+		case verb == "require" && len(args) >= 2:
+			mod.require[args[0]] = args[1]
+		case verb == "replace":
+			// old [version] => new [version]; only local targets matter.
+			for i, a := range args {
+				if a == "=>" && i+1 < len(args) && isLocalModulePath(args[i+1]) {
+					target := args[i+1]
+					if !filepath.IsAbs(target) {
+						target = filepath.Join(dir, target)
+					}
+					mod.replace[args[0]] = target
+				}
+			}
+		}
+	})
+	return mod, nil
+}
+
+// isLocalModulePath reports whether a replacement in a go.mod file is a
+// directory rather than a module path, which the go command requires to
+// start with ./ or ../ if it is relative.
+func isLocalModulePath(path string) bool {
+	return filepath.IsAbs(path) || path == "." || path == ".." ||
+		strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../")
+}
+
+// parseGoWork returns the directories named by use directives in the
+// go.work file at path, relative ones taken relative to its directory.
+func parseGoWork(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	forEachGoModDirective(data, func(verb string, args []string) {
+		if verb == "use" && len(args) >= 1 {
+			dir := args[0]
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(filepath.Dir(path), dir)
+			}
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+	})
+	return dirs, nil
+}
+
+// forEachGoModDirective calls fn for each directive in a go.mod or
+// go.work file, with the directives in a block such as require ( ... )
+// each reported under the block's verb. Comments are dropped and quoted
+// arguments unquoted.
+func forEachGoModDirective(data []byte, fn func(verb string, args []string)) {
+	block := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "//")
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			fields[i] = strings.Trim(f, "\"`")
+		}
+		switch {
+		case len(fields) == 0:
+		case block != "" && fields[0] == ")":
+			block = ""
+		case block != "":
+			fn(block, fields)
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		default:
+			fn(fields[0], fields[1:])
+		}
+	}
+}
+
+// findUp returns the nearest directory at or above dir containing name,
+// or "" if there is none.
+func findUp(dir, name string) string {
+	return findProjectRoot(dir, []string{name})
+}
+
+// goModules returns the go.mod files in effect in dir: those of the
+// workspace's modules, in go.work order, if dir is in a workspace, and
+// otherwise that of the module containing dir.
+func goModules(dir string) []*goModFile {
+	mods, _ := goModulesIn(dir)
+	return mods
+}
+
+// goModulesIn is goModules, also reporting whether dir is in a go.work
+// workspace.
+func goModulesIn(dir string) ([]*goModFile, bool) {
+	var dirs []string
+	work := false
+	if ws := findUp(dir, "go.work"); ws != "" {
+		dirs, _ = parseGoWork(filepath.Join(ws, "go.work"))
+		work = true
+	} else if m := findUp(dir, "go.mod"); m != "" {
+		dirs = []string{m}
+	}
+	var mods []*goModFile
+	for _, d := range dirs {
+		if mod, err := parseGoMod(d); err == nil {
+			mods = append(mods, mod)
+		}
+	}
+	return mods, work
+}
+
+// goWorkspace is the Go workspace containing a directory: the modules of
+// a go.work file, or the module containing the directory, with the local
+// directories their go.mod files replace modules with.
+type goWorkspace struct {
+	work bool         // defined by a go.work file
+	mods []*goModFile // see findGoWorkspace
+}
+
+// findGoWorkspace returns the workspace containing dir, or nil if dir is
+// not in a Go module. Its modules are the go.work file's use
+// directories, or the module containing dir, followed by the local
+// directories their go.mod files replace modules with. A replacement
+// directory without a go.mod file takes the path of the module it
+// replaces.
+func findGoWorkspace(dir string) *goWorkspace {
+	mods, work := goModulesIn(dir)
+	if len(mods) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, mod := range mods {
+		seen[mod.dir] = true
+	}
+	for _, mod := range mods {
+		for _, path := range sortedKeys(mod.replace) {
+			target := mod.replace[path]
+			if seen[target] || existingDir(target) == "" {
+				continue
+			}
+			seen[target] = true
+			rm, err := parseGoMod(target)
+			if err != nil {
+				rm = &goModFile{dir: target, module: path}
+			}
+			mods = append(mods, rm)
+		}
+	}
+	return &goWorkspace{work: work, mods: mods}
+}
+
+// roots returns the directories of the workspace's modules, to be
+// searched as roots: all of them in a go.work workspace, and otherwise
+// those replacing required modules.
+func (ws *goWorkspace) roots() []string {
+	mods := ws.mods
+	if !ws.work {
+		// The module containing dir is already searched through pwd.
+		mods = mods[1:]
+	}
+	var roots []string
+	for _, mod := range mods {
+		roots = append(roots, mod.dir)
+	}
+	return roots
+}
+
+// labeler returns a function that labels paths with the path of the
+// workspace module they belong to, or nil if there is only one module and
+// labels would say nothing.
+func (ws *goWorkspace) labeler() func(path string) string {
+	if ws == nil || len(ws.mods) < 2 {
+		return nil
+	}
+	return func(path string) string {
+		var best *goModFile
+		for _, mod := range ws.mods {
+			if path == mod.dir || strings.HasPrefix(path, mod.dir+string(filepath.Separator)) {
+				if best == nil || len(mod.dir) > len(best.dir) {
+					best = mod
+				}
+			}
+		}
+		if best == nil {
+			return ""
+		}
+		return best.module
+	}
+}
+
+// escapeModulePath escapes a module path for use in the module cache,
+// where each upper-case letter is written as "!" and its lower-case
+// equivalent.
+func escapeModulePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// goImportResolver maps Go import paths to source directories.
+type goImportResolver struct {
+	env  goEnv
+	mods []*goModFile
+}
+
+func newGoImportResolver(pwd string) *goImportResolver {
+	return &goImportResolver{env: loadGoEnv(), mods: goModules(pwd)}
+}
+
+// dir returns the directory holding the package with import path pkg, or
+// "" if it can't be found. Packages of the workspace's own modules and
+// local replacements are found in place, required modules in the module
+// cache, and others in GOROOT or GOPATH. Modules are tried in go.work
+// order, so the first module requiring a module picks its version, and
+// the requirements and replacements of each longest path first, so a
+// nested module wins over the one containing it.
+func (r *goImportResolver) dir(pkg string) string {
+	for _, mod := range r.mods {
+		if sub, ok := cutModulePath(pkg, mod.module); ok {
+			if d := existingDir(filepath.Join(mod.dir, sub)); d != "" {
+				return d
+			}
+		}
+		for _, path := range longestFirst(mod.replace) {
+			if sub, ok := cutModulePath(pkg, path); ok {
+				if d := existingDir(filepath.Join(mod.replace[path], sub)); d != "" {
+					return d
+				}
+			}
+		}
+	}
+	if r.env.modcache != "" {
+		for _, mod := range r.mods {
+			for _, path := range longestFirst(mod.require) {
+				version := mod.require[path]
+				sub, ok := cutModulePath(pkg, path)
+				if !ok {
+					continue
+				}
+				modDir := filepath.Join(r.env.modcache, escapeModulePath(path)+"@"+version)
+				if d := existingDir(filepath.Join(modDir, sub)); d != "" {
+					return d
+				}
+			}
+		}
+	}
+	if r.env.goroot != "" {
+		if d := existingDir(filepath.Join(r.env.goroot, "src", pkg)); d != "" {
+			return d
+		}
+	}
+	for _, gopath := range r.env.gopath {
+		if d := existingDir(filepath.Join(gopath, "src", pkg)); d != "" {
+			return d
+		}
+	}
+	return ""
+}
+
+// longestFirst returns the module paths keying m, longest first and
+// otherwise in order.
+func longestFirst(m map[string]string) []string {
+	paths := sortedKeys(m)
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+	return paths
+}
+
+// cutModulePath returns the path of pkg within the module with path mod,
+// if it is in that module.
+func cutModulePath(pkg, mod string) (string, bool) {
+	if pkg == mod {
+		return "", true
+	}
+	return strings.CutPrefix(pkg, mod+"/")
+}
+
+func existingDir(path string) string {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return path
+	}
+	return ""
+}
+
+// resolveImportPattern splits a pattern that starts with a Go import path,
+// such as net/http/server.go or golang.org/x/term/...go, into the package
+// directory and the rest of the pattern to search for below it. The
+// longest leading run of exact segments naming a package is used. It
+// returns ok false if the pattern doesn't start with an import path.
+func (r *goImportResolver) resolveImportPattern(pattern string) (dir, rest string, ok bool) {
+	parts := strings.Split(pattern, "/")
+	fixed := 0
+	for fixed < len(parts)-1 && parts[fixed] != "" && !strings.Contains(parts[fixed], "...") {
+		fixed++
+	}
+	for i := fixed; i > 0; i-- {
+		if d := r.dir(strings.Join(parts[:i], "/")); d != "" {
+			return d, strings.Join(parts[i:], "/"), true
+		}
+	}
+	return "", "", false
+}
+
+// newImportFallbackIter returns an iterator over the results of first
+// or, if there are none, over the results of searching for pattern as a
+// Go import path followed by a pattern within the package directory.
+func newImportFallbackIter(first *searchIter, pattern, pwd string) *searchIter {
+	return newFallbackIter(first, func() *searchIter {
+		dir, rest, ok := newGoImportResolver(pwd).resolveImportPattern(pattern)
+		if !ok {
+			return nil
+		}
+		iter, err := newSearchIter([]string{dir}, rest, first.opts)
+		if err != nil {
+			return nil
+		}
+		return iter
+	})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseGoMod(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), `module example.com/a // comment
+
+go 1.22
+
+require example.com/b v1.0.0
+
+require (
+	example.com/C v1.2.0 // indirect
+	"example.com/d" v0.1.0
+)
+
+replace example.com/b => ../b
+replace example.com/C v1.2.0 => example.com/fork v1.0.0
+replace (
+	example.com/d => /abs/d
+)
+`)
+	mod, err := parseGoMod(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mod.module != "example.com/a" {
+		t.Errorf("module = %q", mod.module)
+	}
+	wantRequire := map[string]string{"example.com/b": "v1.0.0", "example.com/C": "v1.2.0", "example.com/d": "v0.1.0"}
+	if !maps.Equal(mod.require, wantRequire) {
+		t.Errorf("require = %v, want %v", mod.require, wantRequire)
+	}
+	wantReplace := map[string]string{"example.com/b": filepath.Join(dir, "../b"), "example.com/d": "/abs/d"}
+	if !maps.Equal(mod.replace, wantReplace) {
+		t.Errorf("replace = %v, want %v", mod.replace, wantReplace)
+	}
+}
+
+// TestImportDirWorkspaceOrder checks that with a go.work whose modules
+// require different versions of a module, the first module in go.work
+// order picks the version, and that a nested module wins over the module
+// containing it.
+func TestImportDirWorkspaceOrder(t *testing.T) {
+	tmp := t.TempDir()
+	cache := filepath.Join(tmp, "cache")
+	for _, d := range []string{
+		"example.com/lib@v1.0.0/sub",
+		"example.com/lib@v2.0.0/sub",
+		"example.com/lib/nested@v0.5.0/sub",
+		"example.com/!upper@v1.0.0",
+	} {
+		if err := os.MkdirAll(filepath.Join(cache, d), 0o777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws := filepath.Join(tmp, "ws")
+	writeFile(t, filepath.Join(ws, "go.work"), "go 1.22\n\nuse (\n\t./z\n\t./a\n)\n")
+	writeFile(t, filepath.Join(ws, "z/go.mod"), "module example.com/z\n\nrequire (\n\texample.com/lib v2.0.0\n\texample.com/lib/nested v0.5.0\n)\n")
+	writeFile(t, filepath.Join(ws, "a/go.mod"), "module example.com/a\n\nrequire example.com/lib v1.0.0\nrequire example.com/Upper v1.0.0\n")
+	if err := os.MkdirAll(filepath.Join(ws, "a/pkg"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &goImportResolver{env: goEnv{modcache: cache}, mods: goModules(filepath.Join(ws, "a"))}
+	tests := []struct {
+		pkg, want string
+	}{
+		{"example.com/lib/sub", filepath.Join(cache, "example.com/lib@v2.0.0/sub")},
+		{"example.com/lib/nested/sub", filepath.Join(cache, "example.com/lib/nested@v0.5.0/sub")},
+		{"example.com/Upper", filepath.Join(cache, "example.com/!upper@v1.0.0")},
+		{"example.com/a/pkg", filepath.Join(ws, "a/pkg")},
+		{"example.com/missing", ""},
+	}
+	for _, tt := range tests {
+		// Map iteration order varies, so try repeatedly.
+		for i := 0; i < 20; i++ {
+			if got := r.dir(tt.pkg); got != tt.want {
+				t.Errorf("dir(%q) = %q, want %q", tt.pkg, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFindGoWorkspace(t *testing.T) {
+	tmp := t.TempDir()
+	if ws := findGoWorkspace(tmp); ws != nil {
+		t.Fatalf("findGoWorkspace outside a module = %v, want nil", ws)
+	}
+
+	// A single module replacing a required module with a local directory
+	// without a go.mod file.
+	writeFile(t, filepath.Join(tmp, "m/go.mod"), "module example.com/m\n\nreplace example.com/dep => ../dep\n")
+	if err := os.MkdirAll(filepath.Join(tmp, "dep/pkg"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	ws := findGoWorkspace(filepath.Join(tmp, "m"))
+	if ws == nil || ws.work {
+		t.Fatalf("findGoWorkspace(m) = %+v, want a module", ws)
+	}
+	if got, want := ws.roots(), []string{filepath.Join(tmp, "dep")}; !slices.Equal(got, want) {
+		t.Errorf("roots = %v, want %v", got, want)
+	}
+	label := ws.labeler()
+	if got := label(filepath.Join(tmp, "dep/pkg/x.go")); got != "example.com/dep" {
+		t.Errorf("label in replacement = %q, want example.com/dep", got)
+	}
+	if got := label(filepath.Join(tmp, "m/x.go")); got != "example.com/m" {
+		t.Errorf("label in module = %q, want example.com/m", got)
+	}
+	if got := label(filepath.Join(tmp, "mx/x.go")); got != "" {
+		t.Errorf("label outside = %q, want none", got)
+	}
+
+	// A go.work workspace: all its modules are roots.
+	writeFile(t, filepath.Join(tmp, "w/go.work"), "use (\n\t./b\n\t./a\n)\n")
+	writeFile(t, filepath.Join(tmp, "w/a/go.mod"), "module example.com/a\n")
+	writeFile(t, filepath.Join(tmp, "w/b/go.mod"), "module example.com/b\n")
+	ws = findGoWorkspace(filepath.Join(tmp, "w/a"))
+	want := []string{filepath.Join(tmp, "w/b"), filepath.Join(tmp, "w/a")}
+	if ws == nil || !ws.work || !slices.Equal(ws.roots(), want) {
+		t.Errorf("findGoWorkspace(w/a) = %+v, want roots %v", ws, want)
+	}
+
+	var none *goWorkspace
+	if none.labeler() != nil {
+		t.Errorf("labeler of no workspace is non-nil")
+	}
+}
+
+func TestParseGoWork(t *testing.T) {
+	tmp := t.TempDir()
+	work := filepath.Join(tmp, "ws/go.work")
+	other := filepath.Join(tmp, "other")
+	writeFile(t, work, "go 1.22\n\nuse ./a\nuse (\n\tb // comment\n\t../up\n\t"+other+"\n)\n")
+	got, err := parseGoWork(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(tmp, "ws/a"),
+		filepath.Join(tmp, "ws/b"),
+		filepath.Join(tmp, "up"),
+		other,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseGoWork = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// testEvent is an event of go test -json output, as described by go doc
+// test2json.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// testFailureRE matches an indented line logged by a failing test, such
+// as
+//
+//	foo_test.go:12: got 1, want 2
+var testFailureRE = regexp.MustCompile(`^\s+([^\s:]+\.go):(\d+): ?(.*)$`)
+
+// testFailure is a failed test, with the failure locations it logged.
+type testFailure struct {
+	pkg, test string
+	locs      []testLocation
+	output    []string
+}
+
+// testLocation is a location logged by a test, relative to its package
+// directory, and the first line of its message.
+type testLocation struct {
+	file string
+	line int
+	msg  string
+}
+
+// parseTestFailures returns the tests that failed in the go test -json
+// output in r, grouped by package in the order the packages first
+// failed. Lines of r that aren't JSON, such as build errors, are ignored.
+func parseTestFailures(r io.Reader) ([]testFailure, error) {
+	type key struct{ pkg, test string }
+	output := make(map[key][]string)
+	var failed []key
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var ev testEvent
+		if json.Unmarshal(sc.Bytes(), &ev) != nil || ev.Test == "" {
+			continue
+		}
+		k := key{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			output[k] = append(output[k], strings.TrimRight(ev.Output, "\n"))
+		case "fail":
+			failed = append(failed, k)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	var pkgs []string
+	byPkg := make(map[string][]testFailure)
+	for _, k := range failed {
+		f := testFailure{pkg: k.pkg, test: k.test, output: output[k]}
+		for _, line := range output[k] {
+			if m := testFailureRE.FindStringSubmatch(line); m != nil {
+				n, _ := strconv.Atoi(m[2])
+				f.locs = append(f.locs, testLocation{file: m[1], line: n, msg: m[3]})
+			}
+		}
+		if _, ok := byPkg[k.pkg]; !ok {
+			pkgs = append(pkgs, k.pkg)
+		}
+		byPkg[k.pkg] = append(byPkg[k.pkg], f)
+	}
+	var failures []testFailure
+	for _, pkg := range pkgs {
+		failures = append(failures, byPkg[pkg]...)
+	}
+	return failures, nil
+}
+
+// panicLocation returns the innermost frame in the package directory
+// dir of the stack trace a test printed when it panicked, if any.
+func panicLocation(f testFailure, dir string) []testLocation {
+	frames, _ := parseTrace(strings.NewReader(strings.Join(f.output, "\n")))
+	for _, fr := range frames {
+		if filepath.Dir(fr.file) == dir {
+			return []testLocation{{file: filepath.Base(fr.file), line: fr.line, msg: "panic in " + fr.fn}}
+		}
+	}
+	return nil
+}
+
+// findTestDecl returns the file and line declaring the top-level test of
+// test, which may name a subtest, among the _test.go files in dir.
+func findTestDecl(dir, test string) (string, int) {
+	name, _, _ := strings.Cut(test, "/")
+	prefix := "func " + name + "("
+	files, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		for n := 1; sc.Scan(); n++ {
+			if strings.HasPrefix(sc.Text(), prefix) {
+				f.Close()
+				return file, n
+			}
+		}
+		f.Close()
+	}
+	return "", 0
+}
+
+// newTestFailureIter returns an iterator over the failures in the go test
+// -json output in r: one result for each failure location logged or, if
+// it panicked, for where, or otherwise for the test's declaration, unless
+// it failed only because a subtest did. Each result has the test's
+// declaration as its alternate. Tests whose packages can't be found
+// locally are dropped.
+func newTestFailureIter(r io.Reader, pwd string) (*searchIter, error) {
+	failures, err := parseTestFailures(r)
+	if err != nil {
+		return nil, err
+	}
+	imports := newGoImportResolver(pwd)
+	failedSub := make(map[string]bool)
+	for _, f := range failures {
+		if i := strings.LastIndex(f.test, "/"); i >= 0 {
+			failedSub[f.pkg+" "+f.test[:i]] = true
+		}
+	}
+
+	var ms []match
+	for _, f := range failures {
+		dir := imports.dir(f.pkg)
+		if dir == "" {
+			continue
+		}
+		declFile, declLine := findTestDecl(dir, f.test)
+		var decl *match
+		if declFile != "" {
+			decl = &match{path: declFile, line: declLine, text: f.pkg + " " + f.test}
+		}
+		locs := f.locs
+		if len(locs) == 0 {
+			locs = panicLocation(f, dir)
+		}
+		for _, loc := range locs {
+			ms = append(ms, match{
+				path: filepath.Join(dir, loc.file),
+				line: loc.line,
+				text: f.pkg + " " + f.test + ": " + loc.msg,
+				alt:  decl,
+			})
+		}
+		if len(locs) == 0 && decl != nil && !failedSub[f.pkg+" "+f.test] {
+			ms = append(ms, *decl)
+		}
+	}
+	return newMatchIter(ms), nil
+}
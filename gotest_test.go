@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestFailures(t *testing.T) {
+	in := `{"Action":"run","Package":"example.com/a","Test":"TestOK"}
+{"Action":"pass","Package":"example.com/a","Test":"TestOK"}
+{"Action":"run","Package":"example.com/b","Test":"TestB"}
+{"Action":"output","Package":"example.com/b","Test":"TestB","Output":"=== RUN   TestB\n"}
+{"Action":"output","Package":"example.com/b","Test":"TestB","Output":"    b_test.go:12: got 1, want 2\n"}
+{"Action":"fail","Package":"example.com/b","Test":"TestB"}
+# example.com/c [build failed]
+{"Action":"output","Package":"example.com/a","Test":"TestA/sub","Output":"    a_test.go:30: bad\n"}
+{"Action":"fail","Package":"example.com/a","Test":"TestA/sub"}
+{"Action":"fail","Package":"example.com/a","Test":"TestA"}
+{"Action":"output","Package":"example.com/b","Test":"TestB2","Output":"        helper_test.go:5: deep\n"}
+{"Action":"fail","Package":"example.com/b","Test":"TestB2"}
+{"Action":"fail","Package":"example.com/b"}
+`
+	got, err := parseTestFailures(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type failure struct {
+		pkg, test string
+		locs      []testLocation
+	}
+	var gotFailures []failure
+	for _, f := range got {
+		gotFailures = append(gotFailures, failure{f.pkg, f.test, f.locs})
+	}
+	// Grouped by package, in the order packages first failed.
+	want := []failure{
+		{"example.com/b", "TestB", []testLocation{{"b_test.go", 12, "got 1, want 2"}}},
+		{"example.com/b", "TestB2", []testLocation{{"helper_test.go", 5, "deep"}}},
+		{"example.com/a", "TestA/sub", []testLocation{{"a_test.go", 30, "bad"}}},
+		{"example.com/a", "TestA", nil},
+	}
+	if !reflect.DeepEqual(gotFailures, want) {
+		t.Errorf("parseTestFailures =\n\t%v\nwant\n\t%v", gotFailures, want)
+	}
+}
+
+func TestPanicLocation(t *testing.T) {
+	dir := "/src/m/pkg"
+	f := testFailure{output: []string{
+		"=== RUN   TestPanic",
+		"--- FAIL: TestPanic (0.00s)",
+		"panic: boom [recovered]",
+		"",
+		"goroutine 7 [running]:",
+		"testing.tRunner.func1.2({0x1, 0x2})",
+		"\t/usr/local/go/src/testing/testing.go:1632 +0x230",
+		"example.com/m/pkg.helper(...)",
+		"\t/src/m/pkg/helper.go:9",
+		"example.com/m/pkg.TestPanic(0xc0001)",
+		"\t/src/m/pkg/pkg_test.go:14 +0x18",
+	}}
+	want := []testLocation{{"helper.go", 9, "panic in example.com/m/pkg.helper"}}
+	if got := panicLocation(f, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("panicLocation = %v, want %v", got, want)
+	}
+	if got := panicLocation(f, "/elsewhere"); got != nil {
+		t.Errorf("panicLocation elsewhere = %v, want none", got)
+	}
+}
+
+func TestFindTestDecl(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a_test.go"), "package a\n\nfunc TestA(t *testing.T) {}\n")
+	writeFile(t, filepath.Join(dir, "b_test.go"), "package a\n\nimport \"testing\"\n\nfunc TestAB(t *testing.T) {}\n\nfunc TestB(t *testing.T) {\n}\n")
+	tests := []struct {
+		test string
+		file string
+		line int
+	}{
+		{"TestA", "a_test.go", 3},
+		{"TestB", "b_test.go", 7},
+		{"TestB/sub/case", "b_test.go", 7},
+		{"TestC", "", 0},
+	}
+	for _, tt := range tests {
+		file, line := findTestDecl(dir, tt.test)
+		if tt.file != "" {
+			tt.file = filepath.Join(dir, tt.file)
+		}
+		if file != tt.file || line != tt.line {
+			t.Errorf("findTestDecl(%q) = %s:%d, want %s:%d", tt.test, file, line, tt.file, tt.line)
+		}
+	}
+}
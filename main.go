@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
@@ -12,6 +15,39 @@ func main() {
 	mtime := flag.Bool("m", false, "sort glob results by mtime (newest first)")
 	printAll := flag.Bool("n", false, "print all matches, don't invoke editor")
 	interactive := flag.Bool("a", false, "interactive file picker")
+	dirs := flag.Bool("d", false, "match directories instead of files")
+	fromStdin := flag.Bool("f", false, "filter paths read from stdin (newline or NUL separated) instead of searching")
+	errorList := flag.Bool("e", false, "pick a location from compiler or linter output (file:line:col: message) read from stdin or the file argument")
+	var gitSrc gitSources
+	flag.BoolVar(&gitSrc.tracked, "G", false, "candidates are the files tracked by git, instead of searching")
+	flag.BoolVar(&gitSrc.modified, "M", false, "candidates are the files with changes in git, staged or not")
+	flag.BoolVar(&gitSrc.untracked, "U", false, "candidates are the files untracked by git (and not ignored)")
+	flag.StringVar(&gitSrc.diffBase, "diff", "", "candidates are the files changed since the branch point with git `revision`")
+	conflicts := flag.Bool("conflicts", false, "open the files with merge conflicts at their first conflict (with -a, pick a conflict)")
+	rev := flag.String("rev", "", "open the matching file as it was at git `revision` (or write pattern@revision)")
+	alt := flag.Bool("alt", false, "open the counterpart of the file matching pattern (foo_test.go for foo.go, .h for .c, ...)")
+	testJSON := flag.Bool("gotest", false, "pick a failure from go test -json output read from stdin or the file argument (Ctrl-O opens the test's declaration)")
+	trace := flag.Bool("trace", false, "pick a frame of the Go stack trace read from stdin or the file argument")
+	printSel := flag.Bool("p", false, "print the chosen paths instead of invoking the editor (with -a, Tab marks several)")
+	nulOut := flag.Bool("0", false, "print NUL-terminated paths (with -n or -p)")
+	jsonOut := flag.Bool("json", false, "print JSON Lines with path, root, relative path, size, mtime and matched spans (with -n or -p)")
+	quickfix := flag.Bool("quickfix", false, "print path:line:col: entries for vim's quickfix list (with -n or -p)")
+	execCmd := flag.String("x", "", "run `command` for each match, in parallel; {} {/} {//} {.} {/.} {rel} expand to the path, base name, dir, path and base name without extension, and root-relative path")
+	execBatchCmd := flag.String("X", "", "run `command` once with all matches; placeholders as for -x")
+	jobs := flag.Int("j", runtime.NumCPU(), "number of concurrent -x commands")
+	shell := flag.String("shell", "", "print the integration script for `shell` (bash, zsh or fish) and exit")
+	completeWord := flag.String("complete", "", "print completions for the partial `pattern` (used by the shell integration)")
+	showStats := flag.Bool("stats", false, "report search statistics on stderr")
+	xdev := flag.Bool("xdev", false, "don't descend into directories on other filesystems")
+	skipFS := flag.String("skipfs", "", "comma-separated filesystem types not to descend into (e.g. nfs,fuse.sshfs,proc)")
+	where := flag.String("where", "", "comma-separated file predicates: mtime<DUR, mtime>DUR, today, size<N, size>N, exec, text, binary, mine; negate with !")
+	var types, typeAdds listFlag
+	flag.Var(&types, "t", "only files of type `name` (repeatable; -t list shows types)")
+	flag.Var(&typeAdds, "type-add", "define or extend a file type: `name:rule,...` with globs or #!interpreter (repeatable)")
+	project := flag.Bool("project", false, "search from the project root (the nearest directory with .git, go.mod, go.work or a configured marker) instead of the current directory")
+	follow := flag.String("follow", "explicit", "follow symlinked directories: never, explicit (exact pattern segments only) or always")
+	columns := flag.String("columns", "", "comma-separated columns to show in the picker: status (git), size, mtime, root")
+	showConfig := flag.Bool("config", false, "print the effective configuration and exit")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: edit [flags] <pattern>\n\n")
 		fmt.Fprintf(os.Stderr, "Search $EDITPATH directories for files matching pattern and open in $EDITOR.\n\n")
@@ -19,163 +55,591 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  foo.go          simple filename lookup\n")
 		fmt.Fprintf(os.Stderr, "  ...go           recursive, files ending in 'go'\n")
 		fmt.Fprintf(os.Stderr, "  .../cmd/...go   recursive, dir 'cmd', files ending in 'go'\n")
-		fmt.Fprintf(os.Stderr, "  foo.../bar      dirs starting with 'foo', then file 'bar'\n\n")
+		fmt.Fprintf(os.Stderr, "  foo.../bar      dirs starting with 'foo', then file 'bar'\n")
+		fmt.Fprintf(os.Stderr, "  -t go ...       recursive, Go files (the pattern defaults to ... with -t)\n")
+		fmt.Fprintf(os.Stderr, "  -d .../cmd      recursive, directories named 'cmd'\n")
+		fmt.Fprintf(os.Stderr, "  @api/...go      recursive, only in the root named 'api' (EDITPATH=api=~/src/api:...)\n")
+		fmt.Fprintf(os.Stderr, "  ^/...go         recursive, only in the project root (see -project)\n")
+		fmt.Fprintf(os.Stderr, "  ../.../main.go  recursive from the parent directory; ~ and $VAR work too\n")
+		fmt.Fprintf(os.Stderr, "  net/http/...go  Go package sources, if nothing else matches\n")
+		fmt.Fprintf(os.Stderr, "  -f ...go        paths from stdin ending in 'go' (git ls-files | edit -f ...go)\n")
+		fmt.Fprintf(os.Stderr, "  -M ...go        Go files with uncommitted changes (-G tracked, -U untracked, -diff main)\n")
+		fmt.Fprintf(os.Stderr, "  -trace          frames of a Go stack trace from stdin (go test 2>&1 | edit -trace)\n")
+		fmt.Fprintf(os.Stderr, "  -e              errors from stdin (go vet ./... 2>&1 | edit -e)\n")
+		fmt.Fprintf(os.Stderr, "  -gotest         test failures from stdin (go test -json ./... | edit -gotest)\n")
+		fmt.Fprintf(os.Stderr, "  -alt foo.go     the counterpart of foo.go, foo_test.go\n")
+		fmt.Fprintf(os.Stderr, "  foo.go@HEAD~3   foo.go as it was three commits ago (read-only copy)\n")
+		fmt.Fprintf(os.Stderr, "  -a -conflicts   pick one of the merge conflicts\n")
+		fmt.Fprintf(os.Stderr, "  -a -columns status,mtime ...go\n")
+		fmt.Fprintf(os.Stderr, "                  pick with git status and modification times shown\n\n")
+		fmt.Fprintf(os.Stderr, "Settings are also read from %s and the nearest %s;\n", globalConfigPath(), projectConfigName)
+		fmt.Fprintf(os.Stderr, "see -config.\n\n")
 		fmt.Fprintf(os.Stderr, "Flags:\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
 
-	if flag.NArg() < 1 {
+	// The settings flags (-m, -follow, -xdev, -skipfs, -project, -columns)
+	// take effect through the config, which they override.
+	pwd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
+	cfg, err := loadConfig(pwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
+	var settings settingFlags
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "m":
+			settings.mtime = mtime
+		case "follow":
+			settings.follow = follow
+		case "xdev":
+			settings.xdev = xdev
+		case "project":
+			settings.project = project
+		case "skipfs":
+			settings.skipFS = skipFS
+		case "columns":
+			settings.columns = columns
+		}
+	})
+	if err := cfg.applyFlags(settings); err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
+	if *showConfig {
+		cfg.dump(os.Stdout)
+		return
+	}
+
+	for name, rules := range cfg.types {
+		if err := addFileType(name + ":" + strings.Join(rules, ",")); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, spec := range typeAdds {
+		if err := addFileType(spec); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if len(types) == 1 && types[0] == "list" {
+		listFileTypes(os.Stdout)
+		return
+	}
+
+	if *shell != "" {
+		if err := printShellScript(os.Stdout, *shell); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	mode := runOptions{
+		interactive: *interactive,
+		printAll:    *printAll,
+		printSel:    *printSel,
+		exec:        *execCmd,
+		jobs:        *jobs,
+		editor:      cfg.editor,
+	}
+	if *interactive {
+		mode.picker.label = cfg.workspace.labeler()
+	}
+	mode.picker.columns = cfg.columns
+	mode.picker.rootNames = make(map[string]string)
+	for name, dir := range cfg.rootNames {
+		mode.picker.rootNames[dir] = name
+	}
+	if *execBatchCmd != "" {
+		mode.exec = *execBatchCmd
+		mode.execBatch = true
+	}
+	if countTrue(*nulOut, *jsonOut, *quickfix) > 1 {
+		fmt.Fprintf(os.Stderr, "edit: -0, -json and -quickfix can't be combined\n")
+		flag.Usage()
+		os.Exit(2)
+	}
+	switch {
+	case *jsonOut:
+		mode.format = formatJSON
+	case *quickfix:
+		mode.format = formatQuickfix
+	case *nulOut:
+		mode.format = formatNUL
+	}
+
+	policy, err := parseSymlinkPolicy(cfg.follow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
+	preds, err := parsePredicates(*where)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
+	if len(types) > 0 {
+		p, err := typePredicate(types)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		preds = append(preds, p)
+	}
+	opts := searchOptions{
+		sortByMtime: cfg.sort == "mtime",
+		where:       preds,
+		dirs:        *dirs,
+		ignore:      cfg.ignore,
+		follow:      policy,
+		xdev:        cfg.xdev,
+		skipFS:      cfg.skipFS,
+	}
+	if *showStats {
+		opts.stats = newSearchStats()
+	}
+
+	if *completeWord != "" {
+		complete(os.Stdout, *completeWord, cfg, opts)
+		return
+	}
+
+	args := flag.Args()
+	if *trace || *errorList || *testJSON {
+		in := os.Stdin
+		if len(args) > 0 {
+			f, err := os.Open(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+				os.Exit(1)
+			}
+			in = f
+		}
+		// Paths are looked for in the project root and the other roots.
+		roots := cfg.roots
+		if project := findProjectRoot(pwd, cfg.markers); project != "" {
+			roots = append([]string{project}, roots...)
+		}
+		var iter *searchIter
+		switch {
+		case *errorList:
+			iter, err = newQuickfixIter(in, pwd, roots)
+		case *testJSON:
+			iter, err = newTestFailureIter(in, pwd)
+		default:
+			iter, err = newTraceIter(in, pwd, roots)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		// Locations are chosen in the picker unless they are all wanted.
+		if !mode.printAll && mode.exec == "" {
+			mode.interactive = true
+		}
+		runMode(iter, mode, "")
+		return
+	}
+	if *conflicts {
+		ms, err := findConflicts(pwd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		if len(ms) == 0 {
+			fmt.Fprintln(os.Stderr, "no conflicts")
+			os.Exit(1)
+		}
+		if mode.interactive || mode.printAll || mode.exec != "" {
+			runMode(newMatchIter(ms), mode, "")
+			return
+		}
+		// Otherwise, each file at its first conflict.
+		var first []match
+		seen := make(map[string]bool)
+		for _, m := range ms {
+			if !seen[m.path] {
+				seen[m.path] = true
+				first = append(first, m)
+			}
+		}
+		if mode.printSel {
+			os.Exit(printMatches(nil, mode, "", first...))
+		}
+		targets := make([]string, len(first))
+		for i, m := range first {
+			targets[i] = m.target("")
+		}
+		if err := invokeEditor(mode.editor, targets...); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(args) == 0 && (len(types) > 0 || *fromStdin || gitSrc.any()) {
+		args = []string{"..."}
+	}
+	if len(args) < 1 {
 		flag.Usage()
 		os.Exit(1)
 	}
 
 	// Multiple args means the shell already expanded a glob for us.
 	// Treat them as literal file paths.
-	if flag.NArg() > 1 {
-		files := resolveArgs(flag.Args())
-		if *mtime {
+	if len(args) > 1 && !*fromStdin && !gitSrc.any() {
+		files := resolveArgs(args, *dirs)
+		if opts.sortByMtime {
 			sortByMtime(files)
 		}
 		iter := newSliceIter(files)
-		runMode(iter, *interactive, *printAll, "")
+		runMode(iter, mode, "")
 		return
 	}
 
-	pattern := flag.Arg(0)
+	pattern := args[0]
 	var lineSuffix string
 	pattern, lineSuffix = parseLineSuffix(pattern)
+	if alias, ok := cfg.aliases[pattern]; ok {
+		pattern = alias
+	}
+	pattern, err = expandPattern(pattern, pwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
 
-	if strings.HasPrefix(pattern, "/") {
-		if strings.Contains(pattern, "...") {
-			// Absolute path with ... pattern — extract root and search pattern.
-			parts := strings.Split(pattern, "/")
-			splitAt := 0
-			for i, part := range parts {
-				if strings.Contains(part, "...") {
-					splitAt = i
-					break
-				}
-			}
-			root := strings.Join(parts[:splitAt], "/")
-			if root == "" {
-				root = "/"
-			}
-			searchPattern := strings.Join(parts[splitAt:], "/")
-			iter, err := newSearchIter([]string{root}, searchPattern, *mtime)
+	if *rev == "" {
+		if p, r, ok := splitRevision(pattern, pwd); ok {
+			pattern, *rev = p, r
+		}
+	}
+	if *rev != "" {
+		os.Exit(openRevision(pwd, *rev, pattern, lineSuffix, mode))
+	}
+
+	if *alt {
+		src, err := findFile(pattern, cfg, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		ms, err := findAlternates(src, alternateRules(cfg.alternates), cfg, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			os.Exit(1)
+		}
+		if len(ms) == 0 {
+			fmt.Fprintf(os.Stderr, "edit: no counterpart of %s\n", src)
+			os.Exit(1)
+		}
+		// Several counterparts are chosen between in the picker.
+		if len(ms) > 1 && !mode.printAll && mode.exec == "" {
+			mode.interactive = true
+		}
+		runMode(newMatchIter(ms), mode, "")
+		return
+	}
+
+	if *fromStdin || gitSrc.any() {
+		if len(args) > 1 {
+			fmt.Fprintf(os.Stderr, "edit: -f, -G, -M, -U and -diff take a single pattern\n")
+			os.Exit(1)
+		}
+		if *fromStdin && gitSrc.any() {
+			fmt.Fprintf(os.Stderr, "edit: -f can't be combined with -G, -M, -U or -diff\n")
+			flag.Usage()
+			os.Exit(2)
+		}
+		var in io.Reader = os.Stdin
+		if gitSrc.any() {
+			_, paths, err := gitFiles(pwd, gitSrc)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "edit: %v\n", err)
 				os.Exit(1)
 			}
-			runMode(iter, *interactive, *printAll, lineSuffix)
-			return
+			// Patterns are relative to pwd, as in a search; files
+			// elsewhere in the repository match in full.
+			in = bytes.NewReader(paths)
 		}
-
-		// Absolute path — use directly
-		info, err := os.Stat(pattern)
+		base := pwd
+		root, rest, ok, err := splitScope(pattern, cfg)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
 			os.Exit(1)
 		}
-		if info.IsDir() {
-			fmt.Fprintf(os.Stderr, "edit: %s is a directory\n", pattern)
-			os.Exit(1)
+		if ok {
+			base, pattern = root, rest
 		}
-		if err := invokeEditor(pattern + lineSuffix); err != nil {
+		iter, err := newFilterIter(in, base, pattern, opts)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
 			os.Exit(1)
 		}
+		runMode(iter, mode, lineSuffix)
 		return
 	}
 
-	// Determine roots and search pattern.
-	var roots []string
-	var searchPattern string
-
-	if strings.HasPrefix(pattern, "./") {
-		// Relative to pwd — use pwd as sole root
-		pwd, err := os.Getwd()
+	if strings.HasPrefix(pattern, "/") && !strings.Contains(pattern, "...") {
+		// Absolute path — use directly, in whichever mode
+		info, err := os.Stat(pattern)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
 			os.Exit(1)
 		}
-		roots = []string{pwd}
-		searchPattern = strings.TrimPrefix(pattern, "./")
-	} else {
-		// Search EDITPATH
-		editpath := os.Getenv("EDITPATH")
-		pwd, err := os.Getwd()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		if info.IsDir() && !*dirs {
+			fmt.Fprintf(os.Stderr, "edit: %s is a directory\n", pattern)
 			os.Exit(1)
 		}
-		if editpath != "" {
-			roots = strings.Split(editpath, ":")
+		if !info.IsDir() && *dirs {
+			fmt.Fprintf(os.Stderr, "edit: %s is not a directory\n", pattern)
+			os.Exit(1)
 		}
-		// Append current directory implicitly
-		roots = append(roots, pwd)
-		// Resolve all roots to absolute paths and deduplicate
-		roots = dedup(roots)
-		searchPattern = pattern
+		runMode(newSliceIter([]string{pattern}), mode, lineSuffix)
+		return
 	}
 
-	iter, err := newSearchIter(roots, searchPattern, *mtime)
+	roots, searchPattern, err := resolvePattern(pattern, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		os.Exit(1)
+	}
+	iter, err := newSearchIter(roots, searchPattern, opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
 		os.Exit(1)
 	}
-	runMode(iter, *interactive, *printAll, lineSuffix)
+	// A plain relative pattern that matches nothing may start with a Go
+	// import path, as in net/http/server.go.
+	if searchPattern == pattern {
+		iter = newImportFallbackIter(iter, pattern, pwd)
+	}
+	runMode(iter, mode, lineSuffix)
+}
+
+// findFile returns the file pattern names: the file itself if it exists,
+// or otherwise the first match of a search for it.
+func findFile(pattern string, cfg *config, opts searchOptions) (string, error) {
+	if info, err := os.Stat(pattern); err == nil && !info.IsDir() && !strings.Contains(pattern, "...") {
+		return filepath.Abs(pattern)
+	}
+	roots, searchPattern, err := resolvePattern(pattern, cfg)
+	if err != nil {
+		return "", err
+	}
+	iter, err := newSearchIter(roots, searchPattern, opts)
+	if err != nil {
+		return "", err
+	}
+	defer iter.Close()
+	m, ok := iter.Next()
+	if !ok {
+		return "", fmt.Errorf("no matches for %s", pattern)
+	}
+	return m.path, nil
 }
 
-func runMode(iter *searchIter, interactive, printAll bool, suffix string) {
-	if interactive {
-		sel, err := runPicker(iter)
+// openRevision opens the files matching pattern in git revision rev, or
+// prints their revision:path names, and returns the process exit status.
+// The editor gets read-only copies in a per-user temporary directory,
+// which are removed when it exits.
+func openRevision(pwd, rev, pattern, suffix string, mode runOptions) int {
+	top, rels, err := revisionMatches(pwd, rev, pattern)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		return 1
+	}
+	if len(rels) == 0 {
+		fmt.Fprintln(os.Stderr, "no matches")
+		return 1
+	}
+	if mode.exec != "" {
+		fmt.Fprintln(os.Stderr, "edit: -x and -X don't apply to revisions")
+		return 1
+	}
+	ms := make([]match, len(rels))
+	for i, rel := range rels {
+		ms[i] = match{path: filepath.Join(top, filepath.FromSlash(rel)), root: top, text: rev}
+	}
+	name := func(m match) string {
+		rel, _ := filepath.Rel(top, m.path)
+		return rev + ":" + filepath.ToSlash(rel)
+	}
+
+	if mode.printAll {
+		for _, m := range ms {
+			fmt.Println(name(m))
+		}
+		return 0
+	}
+	sel := ms[:1]
+	if mode.interactive {
+		sel, err = runPicker(newMatchIter(ms), mode.picker)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
-			os.Exit(1)
+			return 1
+		}
+		if len(sel) == 0 {
+			return 0
+		}
+	}
+	if mode.printSel {
+		for _, m := range sel {
+			fmt.Println(name(m))
+		}
+		return 0
+	}
+
+	var paths []string
+	for _, m := range sel {
+		rel, _ := filepath.Rel(top, m.path)
+		path, err := writeRevisionFile(top, rev, filepath.ToSlash(rel))
+		if path != "" {
+			defer os.RemoveAll(filepath.Dir(path))
 		}
-		if sel == "" {
-			os.Exit(0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			return 1
 		}
-		if err := invokeEditor(sel + suffix); err != nil {
+		paths = append(paths, path+suffix)
+	}
+	if err := invokeEditor(mode.editor, paths...); err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runOptions selects how runMode consumes search results.
+type runOptions struct {
+	interactive bool // choose with the interactive picker
+	printAll    bool // print all matches
+	printSel    bool // print the chosen path instead of editing it
+	format      outputFormat
+	exec        string // command to run on each match, or all at once if execBatch
+	execBatch   bool
+	jobs        int    // concurrent runs of exec
+	editor      string // editor command
+	picker      pickerOptions
+}
+
+// runMode consumes iter according to the selected mode and exits. Search
+// statistics, if any, are reported before exiting.
+func runMode(iter *searchIter, mode runOptions, suffix string) {
+	code := consume(iter, mode, suffix)
+	iter.stats.report(os.Stderr)
+	os.Exit(code)
+}
+
+// consume runs the picker, prints, or opens the first match, and returns
+// the process exit status.
+func consume(iter *searchIter, mode runOptions, suffix string) int {
+	if mode.interactive {
+		sel, err := runPicker(iter, mode.picker)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
-			os.Exit(1)
+			return 1
 		}
-		return
+		if len(sel) == 0 {
+			return 0
+		}
+		if mode.exec != "" {
+			// Run the command on the picked results only.
+			mode.interactive = false
+			return consume(newMatchIter(sel), mode, suffix)
+		}
+		if mode.printSel {
+			return printMatches(iter.segs, mode, suffix, sel...)
+		}
+		paths := make([]string, len(sel))
+		for i, m := range sel {
+			paths[i] = m.target(suffix)
+		}
+		if err := invokeEditor(mode.editor, paths...); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			return 1
+		}
+		return 0
 	}
 
-	if printAll {
+	if mode.exec != "" {
+		if mode.execBatch {
+			return runExecBatch(iter, mode.exec)
+		}
+		return runExec(iter, mode.exec, mode.jobs)
+	}
+
+	if mode.printAll {
+		out := newResultWriter(os.Stdout, mode.format, iter.segs, suffix)
 		found := false
 		for {
-			path, ok := iter.Next()
+			m, ok := iter.Next()
 			if !ok {
 				break
 			}
-			fmt.Println(path)
+			if err := out.write(m); err != nil {
+				fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+				return 1
+			}
 			found = true
 		}
+		if err := out.flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			return 1
+		}
 		if !found {
 			fmt.Fprintln(os.Stderr, "no matches")
-			os.Exit(1)
+			return 1
 		}
-		return
+		return 0
 	}
 
 	// Default: first match, invoke editor
-	path, ok := iter.Next()
+	m, ok := iter.Next()
 	iter.Close()
 	if !ok {
 		fmt.Fprintln(os.Stderr, "no matches")
-		os.Exit(1)
+		return 1
 	}
-	if err := invokeEditor(path + suffix); err != nil {
+	if mode.printSel {
+		return printMatches(iter.segs, mode, suffix, m)
+	}
+	if err := invokeEditor(mode.editor, m.target(suffix)); err != nil {
 		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
-		os.Exit(1)
+		return 1
+	}
+	return 0
+}
+
+// printMatches writes the chosen matches to stdout in the output format
+// and returns the process exit status. Matched spans are found with the
+// pattern segments segs, which are nil if the matches didn't come from a
+// pattern.
+func printMatches(segs []segment, mode runOptions, suffix string, ms ...match) int {
+	out := newResultWriter(os.Stdout, mode.format, segs, suffix)
+	for _, m := range ms {
+		if err := out.write(m); err != nil {
+			fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+			return 1
+		}
 	}
+	if err := out.flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "edit: %v\n", err)
+		return 1
+	}
+	return 0
 }
 
-// resolveArgs converts shell-expanded args to absolute paths, filtering to existing files.
-func resolveArgs(args []string) []string {
+// resolveArgs converts shell-expanded args to absolute paths, filtering to
+// existing files, or directories if dirs is set.
+func resolveArgs(args []string, dirs bool) []string {
 	var files []string
 	for _, a := range args {
 		abs, err := filepath.Abs(a)
@@ -183,7 +647,7 @@ func resolveArgs(args []string) []string {
 			continue
 		}
 		info, err := os.Stat(abs)
-		if err != nil || info.IsDir() {
+		if err != nil || info.IsDir() != dirs {
 			continue
 		}
 		files = append(files, abs)
@@ -207,6 +671,38 @@ func parseLineSuffix(s string) (string, string) {
 	return s[:idx], s[idx:]
 }
 
+// listFlag is a repeatable string flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// countTrue returns how many of the flags are set.
+func countTrue(flags ...bool) int {
+	n := 0
+	for _, f := range flags {
+		if f {
+			n++
+		}
+	}
+	return n
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
 // dedup resolves all paths to absolute and removes duplicates, preserving order.
 func dedup(paths []string) []string {
 	seen := make(map[string]bool)
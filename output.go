@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// outputFormat selects how printed results are written.
+type outputFormat int
+
+const (
+	formatLines    outputFormat = iota // one path, or path:line, per line
+	formatNUL                          // NUL-terminated paths
+	formatJSON                         // JSON Lines, see jsonResult
+	formatQuickfix                     // path:line:col: text, for vim's errorformat
+)
+
+// jsonResult is a result as written in JSON Lines format.
+type jsonResult struct {
+	Path  string    `json:"path"`
+	Root  string    `json:"root,omitempty"`
+	Rel   string    `json:"rel"`
+	Size  int64     `json:"size"`
+	Mtime time.Time `json:"mtime"`
+	Line  int       `json:"line,omitempty"`
+	Col   int       `json:"col,omitempty"`
+	Text  string    `json:"text,omitempty"`
+	Spans []span    `json:"spans,omitempty"`
+}
+
+// resultWriter writes results in an output format.
+type resultWriter struct {
+	w      *bufio.Writer
+	format outputFormat
+	segs   []segment // the pattern, for JSON spans
+	line   int       // line number from the pattern's ":<line>" suffix, or 0
+}
+
+func newResultWriter(w io.Writer, format outputFormat, segs []segment, suffix string) *resultWriter {
+	rw := &resultWriter{w: bufio.NewWriter(w), format: format, segs: segs}
+	fmt.Sscanf(strings.TrimPrefix(suffix, ":"), "%d", &rw.line)
+	return rw
+}
+
+// write writes one result, flushing it through to the underlying writer.
+func (rw *resultWriter) write(m match) error {
+	line := m.line
+	if line == 0 {
+		line = rw.line
+	}
+	switch rw.format {
+	case formatNUL:
+		rw.w.WriteString(m.path)
+		rw.w.WriteByte(0)
+
+	case formatJSON:
+		res := jsonResult{Path: m.path, Root: m.root, Rel: rw.rel(m), Line: line, Col: m.col, Text: m.text}
+		if info, err := os.Stat(m.path); err == nil {
+			res.Size = info.Size()
+			res.Mtime = info.ModTime()
+		}
+		if m.root != "" {
+			res.Spans = segmentSpans(rw.segs, res.Rel)
+		}
+		b, err := json.Marshal(res)
+		if err != nil {
+			return err
+		}
+		rw.w.Write(b)
+		rw.w.WriteByte('\n')
+
+	case formatQuickfix:
+		if line == 0 {
+			line = 1
+		}
+		col := m.col
+		if col == 0 {
+			col = 1
+		}
+		text := m.text
+		if text == "" {
+			text = rw.rel(m)
+		}
+		fmt.Fprintf(rw.w, "%s:%d:%d: %s\n", m.path, line, col, text)
+
+	default:
+		// Results with their own line, such as stack frames, are
+		// locations rather than files.
+		if m.line > 0 {
+			fmt.Fprintf(rw.w, "%s:%d\n", m.path, m.line)
+		} else {
+			rw.w.WriteString(m.path)
+			rw.w.WriteByte('\n')
+		}
+	}
+	// Each result goes out as it is found, for consumers such as fzf.
+	return rw.w.Flush()
+}
+
+// rel returns the path of m relative to its root, or the full path if it
+// has none.
+func (rw *resultWriter) rel(m match) string {
+	if m.root == "" {
+		return m.path
+	}
+	rel, err := filepath.Rel(m.root, m.path)
+	if err != nil {
+		return m.path
+	}
+	return rel
+}
+
+// flush writes any buffered output.
+func (rw *resultWriter) flush() error {
+	return rw.w.Flush()
+}
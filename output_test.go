@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResultWriter(t *testing.T) {
+	ms := []match{
+		{path: "/r/a.go", root: "/r"},
+		{path: "/r/b.go", root: "/r", line: 3, col: 4, text: "f"},
+	}
+	tests := []struct {
+		format outputFormat
+		suffix string
+		want   []string // after each result
+	}{
+		{formatLines, "", []string{"/r/a.go\n", "/r/b.go:3\n"}},
+		{formatNUL, "", []string{"/r/a.go\x00", "/r/b.go\x00"}},
+		{formatQuickfix, ":7", []string{"/r/a.go:7:1: a.go\n", "/r/b.go:3:4: f\n"}},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		rw := newResultWriter(&b, tt.format, nil, tt.suffix)
+		want := ""
+		// Each result is written through at once, not when flushed.
+		for i, m := range ms {
+			if err := rw.write(m); err != nil {
+				t.Fatal(err)
+			}
+			want += tt.want[i]
+			if b.String() != want {
+				t.Errorf("format %d: after %d results, output = %q, want %q", tt.format, i+1, b.String(), want)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// matchPath reports whether a path, split into its elements, matches the
+// pattern segments with the same semantics as the filesystem walk: a
+// "..." segment matches any number of directories, wildcard segments
+// don't match hidden names, and the leaf segment matches the last
+// element.
+func matchPath(segs []segment, elems []string) bool {
+	return assignPath(segs, elems, 0, nil)
+}
+
+// assignPath matches like matchPath. If at is non-nil, it also records
+// in at[i] the index of the element matched by segs[i], offset by first,
+// or -1 for "..." segments.
+func assignPath(segs []segment, elems []string, first int, at []int) bool {
+	if len(segs) == 0 {
+		return len(elems) == 0
+	}
+	seg := segs[0]
+	if seg.kind == segRecursive {
+		if at != nil {
+			at[0] = -1
+		}
+		// Skip zero or more (non-hidden) directories.
+		for i := 0; i < len(elems); i++ {
+			if assignPath(segs[1:], elems[i:], first+i, tail(at)) {
+				return true
+			}
+			if strings.HasPrefix(elems[i], ".") {
+				return false
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 || !matchSegment(seg, elems[0]) {
+		return false
+	}
+	if at != nil {
+		at[0] = first
+	}
+	return assignPath(segs[1:], elems[1:], first+1, tail(at))
+}
+
+func tail(at []int) []int {
+	if at == nil {
+		return nil
+	}
+	return at[1:]
+}
+
+// segmentSpans returns, for each wildcard segment of segs, the byte
+// offsets in rel of the path element it matched, or nil if rel doesn't
+// match.
+func segmentSpans(segs []segment, rel string) []span {
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	at := make([]int, len(segs))
+	if !assignPath(segs, elems, 0, at) {
+		return nil
+	}
+	offsets := make([]int, len(elems))
+	off := 0
+	for i, e := range elems {
+		offsets[i] = off
+		off += len(e) + 1
+	}
+	var spans []span
+	for i, seg := range segs {
+		if at[i] < 0 {
+			continue
+		}
+		start := offsets[at[i]]
+		spans = append(spans, span{Segment: seg.pattern, Start: start, End: start + len(elems[at[i]])})
+	}
+	return spans
+}
+
+// span is the part of a relative path matched by one pattern segment.
+type span struct {
+	Segment string `json:"segment"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+}
+
+// matchSegment reports whether a single path element matches a wildcard
+// segment, as the walker would.
+func matchSegment(seg segment, name string) bool {
+	if !strings.Contains(seg.pattern, "...") {
+		return seg.pattern == name
+	}
+	return !strings.HasPrefix(name, ".") && matchWild(seg.pattern, name)
+}
+
+// newFilterIter returns an iterator over the paths read from r that match
+// pattern, instead of searching the filesystem. Paths are separated by
+// newlines or, if the first path ends in a NUL, by NULs.
+// Relative paths are taken relative to base, and matched as given;
+// absolute paths are matched relative to base if they are below it, and
+// in full otherwise. An absolute pattern matches paths in full. Matching
+// paths that don't exist, or don't satisfy the options, are dropped.
+func newFilterIter(r io.Reader, base, pattern string, opts searchOptions) (*searchIter, error) {
+	absPattern := strings.HasPrefix(pattern, "/")
+	segments, err := parsePattern(strings.TrimPrefix(pattern, "./"))
+	if err != nil {
+		return nil, err
+	}
+
+	it := newIter(opts)
+	it.segs = segments
+	// Results are reported relative to what they were matched against.
+	it.root = base
+	if absPattern {
+		it.root = "/"
+	}
+
+	go func() {
+		defer close(it.ch)
+		defer it.stats.finish()
+		it.stats.beginRoot("(stdin)")
+
+		var files []leafFile
+		listed := make(map[string]bool) // inputs such as gitFiles may repeat paths
+		sc := newPathScanner(r)
+		for sc.Scan() {
+			p := sc.Text()
+			if p == "" {
+				continue
+			}
+			abs := p
+			if !filepath.IsAbs(abs) {
+				abs = filepath.Join(base, abs)
+			}
+			abs = filepath.Clean(abs)
+			rel := strings.TrimPrefix(abs, "/")
+			if !absPattern {
+				if r, err := filepath.Rel(base, abs); err == nil && !strings.HasPrefix(r, "..") {
+					rel = r
+				}
+			}
+			elems := strings.Split(filepath.ToSlash(rel), "/")
+			if listed[abs] || !matchPath(segments, elems) || it.ignoredPath(elems) {
+				continue
+			}
+			listed[abs] = true
+			info, err := it.stat(abs)
+			if err != nil || info.IsDir() != it.opts.dirs {
+				continue
+			}
+			if it.opts.sortByMtime {
+				files = append(files, leafFile{abs, info, false})
+				continue
+			}
+			if !it.emitFile(abs, info, false) {
+				return
+			}
+		}
+
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].info.ModTime().After(files[j].info.ModTime())
+		})
+		for _, f := range files {
+			if !it.emitFile(f.path, f.info, false) {
+				return
+			}
+		}
+	}()
+
+	return it, nil
+}
+
+// ignoredPath reports whether any element of a path is ignored.
+func (it *searchIter) ignoredPath(elems []string) bool {
+	for _, e := range elems {
+		if it.ignored(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// newPathScanner returns a scanner over the paths in r, which are
+// NUL-separated if the first path ends in a NUL, and newline-separated
+// otherwise. Deciding on the first path, rather than a buffer of input,
+// lets paths from a slow producer through as they arrive.
+func newPathScanner(r io.Reader) *bufio.Scanner {
+	sep := -1 // not yet known
+	sc := bufio.NewScanner(r)
+	sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if sep < 0 {
+			if i := bytes.IndexAny(data, "\n\x00"); i >= 0 {
+				sep = int(data[i])
+			}
+		}
+		if i := bytes.IndexByte(data, byte(sep)); sep >= 0 && i >= 0 {
+			return i + 1, bytes.TrimSuffix(data[:i], []byte("\r")), nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	return sc
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"main.go", "main.go", true},
+		{"main.go", "cmd/main.go", false},
+		{"...go", "main.go", true},
+		{"...go", "cmd/x/main.go", true},
+		{"...go", "main.gox", false},
+		{"cmd/...go", "cmd/main.go", true},
+		{"cmd/...go", "cmd/x/main.go", true}, // a "..." leaf is recursive
+		{"cmd/main...", "cmd/x/main.go", false},
+		{"cmd/.../main.go", "cmd/x/y/main.go", true},
+		{"cmd/.../main.go", "cmd/main.go", true},
+		{".../internal/...go", "a/internal/b/c.go", true},
+		{"...", "a/b/c", true},
+		// Wildcards and "..." don't reach into hidden names.
+		{"...go", ".git/x.go", false},
+		{"...go", ".x.go", false},
+		{".git/...go", ".git/x.go", true},
+		{"a/...", "a/.hidden", false},
+	}
+	for _, tt := range tests {
+		segs, err := parsePattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matchPath(segs, strings.Split(tt.path, "/")); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentSpans(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         []span
+	}{
+		{"...go", "cmd/main.go", []span{{"...go", 4, 11}}},
+		{"cmd/.../m...", "cmd/x/main.go", []span{{"cmd", 0, 3}, {"m...", 6, 13}}},
+		{"...go", "main.rs", nil},
+	}
+	for _, tt := range tests {
+		segs, err := parsePattern(tt.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := segmentSpans(segs, tt.rel); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("segmentSpans(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestNewPathScanner(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"a\nb c\n", []string{"a", "b c"}},
+		{"a\r\nb", []string{"a", "b"}},
+		{"a\nb\x00c\n", []string{"a", "b\x00c"}},
+		{"a\x00b\nc\x00", []string{"a", "b\nc"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		sc := newPathScanner(strings.NewReader(tt.in))
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("newPathScanner(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// The first path comes through before the writer has written any more.
+func TestNewPathScannerStreams(t *testing.T) {
+	r, w := io.Pipe()
+	go w.Write([]byte("first\n"))
+	sc := newPathScanner(r)
+	if !sc.Scan() || sc.Text() != "first" {
+		t.Fatalf("Scan = %q, %v, want first", sc.Text(), sc.Err())
+	}
+	w.Close()
+	if sc.Scan() {
+		t.Errorf("Scan after close = %q", sc.Text())
+	}
+}
+
+func TestNewFilterIter(t *testing.T) {
+	tmp := t.TempDir()
+	for _, f := range []string{"a.go", "sub/b.go", "sub/c.txt", "out/d.go"} {
+		writeFile(t, filepath.Join(tmp, f), "")
+	}
+	in := strings.Join([]string{
+		"a.go",
+		"sub/b.go",
+		"sub/c.txt",
+		"./a.go",                       // listed twice
+		filepath.Join(tmp, "sub/b.go"), // below base, absolute
+		"missing.go",                   // doesn't exist
+		filepath.Join(tmp, "out/d.go"), // ignored
+	}, "\n")
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"...go", []string{"a.go", "sub/b.go"}},
+		{"sub/...", []string{"sub/b.go", "sub/c.txt"}},
+		{tmp + "/sub/...txt", []string{"sub/c.txt"}},
+	}
+	for _, tt := range tests {
+		it, err := newFilterIter(strings.NewReader(in), tmp, tt.pattern, searchOptions{ignore: []string{"out"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for m, ok := it.Next(); ok; m, ok = it.Next() {
+			rel, _ := filepath.Rel(tmp, m.path)
+			got = append(got, rel)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("newFilterIter(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/term"
 )
@@ -15,8 +19,8 @@ import (
 var brailleFrames = [...]rune{'⠋', '⠙', '⠹', '⠸', '⠼', '⠴', '⠦', '⠧', '⠇', '⠏'}
 
 type picker struct {
-	allResults []string // absolute paths
-	filtered   []int    // indices into allResults matching current search
+	allResults []match
+	filtered   []int // indices into allResults matching current search
 	search     string
 	selected   int // index into filtered
 	offset     int // scroll offset into filtered
@@ -25,13 +29,38 @@ type picker struct {
 	searching  bool
 	pwd        string
 	spinFrame  int
+	marked     map[int]bool // indices into allResults chosen with Tab
+	out        *bufio.Writer
+	width      int // of the terminal, for the columns
+
+	pickerOptions
+	status *gitStatusCache
+	info   map[int]os.FileInfo // by index into allResults, if the columns need it
+}
+
+// pickerColumns are the columns the picker can show besides the path: the
+// git status letter, shown before the path, and the size, the time since
+// the last modification and the root the file was found in, shown in the
+// order given, aligned at the right of the terminal.
+var pickerColumns = []string{"status", "size", "mtime", "root"}
+
+// pickerOptions selects what the picker shows of each result.
+type pickerOptions struct {
+	label     func(path string) string // if non-nil, labels results without text
+	columns   []string                 // see pickerColumns
+	rootNames map[string]string        // root → name, shown in the root column
 }
 
-func newPicker(pwd string) *picker {
+func newPicker(pwd string, out *bufio.Writer) *picker {
 	return &picker{
 		maxVisible: 10,
 		searching:  true,
 		pwd:        pwd,
+		marked:     make(map[int]bool),
+		out:        out,
+		width:      80,
+		status:     newGitStatusCache(),
+		info:       make(map[int]os.FileInfo),
 	}
 }
 
@@ -43,12 +72,48 @@ func (p *picker) displayPath(abs string) string {
 	return abs
 }
 
-func (p *picker) addResult(path string) {
+// display returns how a result is shown: its display path, followed by
+// its line and column if it has them.
+func (p *picker) display(m match) string {
+	dp := p.displayPath(m.path)
+	if m.line > 0 {
+		dp += fmt.Sprintf(":%d", m.line)
+	}
+	if m.col > 0 {
+		dp += fmt.Sprintf(":%d", m.col)
+	}
+	return dp
+}
+
+// describe returns the text shown dimmed after a result, if any.
+func (p *picker) describe(m match) string {
+	if m.text != "" {
+		return m.text
+	}
+	if p.label != nil {
+		return p.label(m.path)
+	}
+	return ""
+}
+
+func (p *picker) addResult(m match) {
+	// The filesystem and git are consulted outside the lock, and git
+	// status in the background, so that drawing doesn't wait on them.
+	var info os.FileInfo
+	if slices.Contains(p.columns, "size") || slices.Contains(p.columns, "mtime") {
+		info, _ = os.Stat(m.path)
+	}
+	if slices.Contains(p.columns, "status") {
+		p.status.load(m.path)
+	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.allResults = append(p.allResults, path)
+	p.allResults = append(p.allResults, m)
+	if info != nil {
+		p.info[len(p.allResults)-1] = info
+	}
 	// Add to filtered set if it matches the current search.
-	if p.matches(path) {
+	if p.matches(m) {
 		p.filtered = append(p.filtered, len(p.allResults)-1)
 	}
 }
@@ -59,14 +124,20 @@ func (p *picker) searchDone() {
 	p.searching = false
 }
 
-// matches reports whether path matches the current search. Must be called
+// matches reports whether m matches the current search. Must be called
 // with p.mu held.
-func (p *picker) matches(path string) bool {
+func (p *picker) matches(m match) bool {
 	if p.search == "" {
 		return true
 	}
-	dp := p.displayPath(path)
-	return strings.Contains(strings.ToLower(dp), strings.ToLower(p.search))
+	return p.contains(m, strings.ToLower(p.search))
+}
+
+// contains reports whether the display path or text of m contains lower,
+// ignoring case.
+func (p *picker) contains(m match, lower string) bool {
+	return strings.Contains(strings.ToLower(p.display(m)), lower) ||
+		strings.Contains(strings.ToLower(m.text), lower)
 }
 
 // setSearch updates the search string, rebuilds the filtered set, and selects
@@ -78,8 +149,7 @@ func (p *picker) setSearch(s string) bool {
 		lower := strings.ToLower(s)
 		var filtered []int
 		for i, r := range p.allResults {
-			dp := p.displayPath(r)
-			if strings.Contains(strings.ToLower(dp), lower) {
+			if p.contains(r, lower) {
 				filtered = append(filtered, i)
 			}
 		}
@@ -137,13 +207,46 @@ func (p *picker) moveDown() {
 	}
 }
 
-func (p *picker) getSelection() string {
+// toggleMark marks or unmarks the selected item and moves down.
+func (p *picker) toggleMark() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if len(p.filtered) == 0 {
-		return ""
+		return
+	}
+	i := p.filtered[p.selected]
+	if p.marked[i] {
+		delete(p.marked, i)
+	} else {
+		p.marked[i] = true
+	}
+	if p.selected < len(p.filtered)-1 {
+		p.selected++
+		p.clampOffset()
 	}
-	return p.allResults[p.filtered[p.selected]]
+}
+
+// getSelection returns the marked items in result order or, if none are
+// marked, the selected item.
+func (p *picker) getSelection() []match {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.marked) > 0 {
+		var idx []int
+		for i := range p.marked {
+			idx = append(idx, i)
+		}
+		sort.Ints(idx)
+		sel := make([]match, len(idx))
+		for j, i := range idx {
+			sel[j] = p.allResults[i]
+		}
+		return sel
+	}
+	if len(p.filtered) == 0 {
+		return nil
+	}
+	return []match{p.allResults[p.filtered[p.selected]]}
 }
 
 // wantMore returns true when the picker needs more results to fill the
@@ -164,38 +267,204 @@ func (p *picker) render() {
 		end = len(p.filtered)
 	}
 
+	rootWidth := 0
+	if slices.Contains(p.columns, "root") {
+		for i := p.offset; i < end; i++ {
+			rootWidth = max(rootWidth, runeLen(p.rootName(p.allResults[p.filtered[i]].root)))
+		}
+		rootWidth = min(rootWidth, p.width/4)
+	}
+
 	linesDown := 0
 	for i := p.offset; i < end; i++ {
 		if linesDown > 0 {
-			fmt.Fprint(os.Stderr, "\r\n")
+			fmt.Fprint(p.out, "\r\n")
+		}
+		// Once anything is marked, a gutter shows which items are.
+		if len(p.marked) > 0 {
+			if p.marked[p.filtered[i]] {
+				fmt.Fprint(p.out, "\033[1;32m•\033[0m ")
+			} else {
+				fmt.Fprint(p.out, "  ")
+			}
+		}
+		m := p.allResults[p.filtered[i]]
+		dp, text := p.display(m), p.describe(m)
+		var right string
+		pad := 0
+		if len(p.columns) > 0 {
+			gutter := 0
+			if len(p.marked) > 0 {
+				gutter = 2
+			}
+			var status string
+			status, right = p.columnText(p.filtered[i], m, rootWidth)
+			if status != "" {
+				fmt.Fprint(p.out, status)
+				gutter += 2
+			}
+			// The path and text get what the columns leave of the
+			// line, if that is enough to be useful.
+			// The last column of the terminal is left empty, so that a
+			// full line doesn't wrap.
+			width := p.width - 1
+			avail := width - gutter - 2 - runeLen(right)
+			if right == "" || avail < 20 {
+				right, avail = "", width-gutter
+			}
+			dp, text = fitLine(dp, text, avail)
+			pad = avail - runeLen(dp)
+			if text != "" {
+				pad -= 2 + runeLen(text)
+			}
+		}
+		fmt.Fprint(p.out, highlightLine(dp, p.search, i == p.selected))
+		if text != "" {
+			fmt.Fprintf(p.out, "  \033[2m%s\033[0m", text)
 		}
-		dp := p.displayPath(p.allResults[p.filtered[i]])
-		fmt.Fprint(os.Stderr, highlightLine(dp, p.search, i == p.selected))
-		fmt.Fprint(os.Stderr, "\033[K")
+		if right != "" {
+			fmt.Fprintf(p.out, "%s  \033[2m%s\033[0m", strings.Repeat(" ", max(pad, 0)), right)
+		}
+		fmt.Fprint(p.out, "\033[K")
 		linesDown++
 	}
 
 	if p.searching {
 		if linesDown > 0 {
-			fmt.Fprint(os.Stderr, "\r\n")
+			fmt.Fprint(p.out, "\r\n")
 		}
-		fmt.Fprintf(os.Stderr, "\033[2m%c\033[0m\033[K", brailleFrames[p.spinFrame%len(brailleFrames)])
+		fmt.Fprintf(p.out, "\033[2m%c\033[0m\033[K", brailleFrames[p.spinFrame%len(brailleFrames)])
 		linesDown++
 	}
 
 	// Clear any leftover lines from a previous longer render.
-	fmt.Fprint(os.Stderr, "\033[J")
+	fmt.Fprint(p.out, "\033[J")
 
 	// Move cursor back to the first line.
 	if linesDown > 1 {
-		fmt.Fprintf(os.Stderr, "\033[%dA", linesDown-1)
+		fmt.Fprintf(p.out, "\033[%dA", linesDown-1)
+	}
+	fmt.Fprint(p.out, "\r")
+	p.out.Flush()
+}
+
+// columnText returns the status column, if shown, and the other columns
+// of the result m at index i of allResults, with the root column rootWidth
+// wide. Must be called with p.mu held.
+func (p *picker) columnText(i int, m match, rootWidth int) (string, string) {
+	info := p.info[i]
+	var status string
+	var cols []string
+	for _, c := range p.columns {
+		switch c {
+		case "status":
+			l := p.status.letter(m.path)
+			switch l {
+			case 'A', '?':
+				status = fmt.Sprintf("\033[32m%c\033[0m ", l)
+			case 'D', 'U':
+				status = fmt.Sprintf("\033[31m%c\033[0m ", l)
+			default:
+				status = fmt.Sprintf("\033[33m%c\033[0m ", l)
+			}
+		case "size":
+			s := ""
+			if info != nil && !info.IsDir() {
+				s = formatSize(info.Size())
+			}
+			cols = append(cols, fmt.Sprintf("%5s", s))
+		case "mtime":
+			s := ""
+			if info != nil {
+				s = formatAge(time.Since(info.ModTime()))
+			}
+			cols = append(cols, fmt.Sprintf("%8s", s))
+		case "root":
+			name := []rune(p.rootName(m.root))
+			if len(name) > rootWidth {
+				name = append([]rune("…"), name[len(name)-rootWidth+1:]...)
+			}
+			cols = append(cols, string(name)+strings.Repeat(" ", rootWidth-len(name)))
+		}
+	}
+	return status, strings.Join(cols, "  ")
+}
+
+// rootName returns how the root column shows root: by its name, if it
+// has one, or else its display path.
+func (p *picker) rootName(root string) string {
+	if root == "" {
+		return ""
+	}
+	if name := p.rootNames[root]; name != "" {
+		return "@" + name
+	}
+	return p.displayPath(root)
+}
+
+// fitLine shortens a display path and its text to fit in width
+// characters, dropping the end of the text first, then the start of the
+// path.
+func fitLine(dp, text string, width int) (string, string) {
+	n := runeLen(dp)
+	if text != "" && n+2+runeLen(text) > width {
+		if w := width - n - 2; w >= 2 {
+			text = string([]rune(text)[:w-1]) + "…"
+		} else {
+			text = ""
+		}
+	}
+	if n > width && width > 1 {
+		dp = "…" + string([]rune(dp)[n-width+1:])
+	}
+	return dp, text
+}
+
+func runeLen(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// formatSize formats a byte count in at most five characters, with a K,
+// M, G or T (binary) suffix as parseSize accepts.
+func formatSize(n int64) string {
+	if n < 1<<10 {
+		return fmt.Sprintf("%dB", n)
 	}
-	fmt.Fprint(os.Stderr, "\r")
+	f := float64(n) / (1 << 10)
+	unit := 0
+	for f >= 1<<10 && unit < 3 {
+		f /= 1 << 10
+		unit++
+	}
+	if f < 10 {
+		return fmt.Sprintf("%.1f%c", f, "KMGT"[unit])
+	}
+	return fmt.Sprintf("%.0f%c", f, "KMGT"[unit])
+}
+
+// formatAge formats the time since a modification in its largest whole
+// unit, as in "3m ago".
+func formatAge(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", max(d/time.Second, 0))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", d/time.Minute)
+	case d < day:
+		return fmt.Sprintf("%dh ago", d/time.Hour)
+	case d < 30*day:
+		return fmt.Sprintf("%dd ago", d/day)
+	case d < 365*day:
+		return fmt.Sprintf("%dmo ago", d/(30*day))
+	}
+	return fmt.Sprintf("%dy ago", d/(365*day))
 }
 
 // clear removes the picker display.
 func (p *picker) clear() {
-	fmt.Fprint(os.Stderr, "\r\033[J")
+	fmt.Fprint(p.out, "\r\033[J")
+	p.out.Flush()
 }
 
 // highlightLine renders a display path with the search match highlighted.
@@ -245,30 +514,41 @@ func highlightLine(dp, search string, isSelected bool) string {
 	return b.String()
 }
 
-// runPicker runs the interactive picker and returns the selected file path,
-// or empty string if cancelled. Returns an error if no results are available.
-func runPicker(iter *searchIter) (string, error) {
+// runPicker runs the interactive picker and returns the selected
+// results: those marked with Tab or, if none are, the one under the cursor.
+// Choosing with Ctrl-O instead of Enter returns the alternate location of
+// each result that has one.
+// It returns no results if cancelled, and an error if no results are
+// available. The picker reads keys from and draws on /dev/tty, so that
+// stdin and stdout remain free for pipelines. The options select what
+// is shown of each result.
+func runPicker(iter *searchIter, opts pickerOptions) ([]match, error) {
 	// Wait for at least one result before showing the picker.
 	first, ok := iter.Next()
 	if !ok {
-		return "", fmt.Errorf("no matches")
+		return nil, fmt.Errorf("no matches")
 	}
 
-	fd := int(os.Stdin.Fd())
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("interactive picker requires a terminal")
+	}
+	defer tty.Close()
+	fd := int(tty.Fd())
 	if !term.IsTerminal(fd) {
-		return "", fmt.Errorf("interactive picker requires a terminal")
+		return nil, fmt.Errorf("interactive picker requires a terminal")
 	}
 
 	oldState, err := term.MakeRaw(fd)
 	if err != nil {
-		return "", fmt.Errorf("failed to set raw mode: %w", err)
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
 	}
 	defer term.Restore(fd, oldState)
 
 	pwd, _ := os.Getwd()
-	p := newPicker(pwd)
-	p.allResults = append(p.allResults, first)
-	p.filtered = []int{0}
+	p := newPicker(pwd, bufio.NewWriter(tty))
+	p.pickerOptions = opts
+	p.addResult(first)
 
 	type keyEvent struct {
 		b   []byte
@@ -278,7 +558,7 @@ func runPicker(iter *searchIter) (string, error) {
 	go func() {
 		buf := make([]byte, 32)
 		for {
-			n, err := os.Stdin.Read(buf)
+			n, err := tty.Read(buf)
 			if err != nil {
 				keyCh <- keyEvent{nil, err}
 				return
@@ -290,7 +570,12 @@ func runPicker(iter *searchIter) (string, error) {
 	}()
 
 	redraw := func() {
-		fmt.Fprint(os.Stderr, "\r")
+		if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+			p.mu.Lock()
+			p.width = w
+			p.mu.Unlock()
+		}
+		fmt.Fprint(p.out, "\r")
 		p.render()
 	}
 
@@ -304,7 +589,7 @@ func runPicker(iter *searchIter) (string, error) {
 
 	for {
 		// Determine whether to pull more results from the iterator.
-		var pullCh <-chan string
+		var pullCh <-chan match
 		p.mu.Lock()
 		needMore := p.wantMore()
 		p.mu.Unlock()
@@ -313,17 +598,20 @@ func runPicker(iter *searchIter) (string, error) {
 		}
 
 		select {
-		case path, ok := <-pullCh:
+		case m, ok := <-pullCh:
 			if !ok {
 				iterDone = true
 				p.searchDone()
 				ticker.Stop()
 				redraw()
 			} else {
-				p.addResult(path)
+				p.addResult(m)
 				redraw()
 			}
 
+		case <-p.status.ready:
+			redraw()
+
 		case <-ticker.C:
 			if !iterDone {
 				p.mu.Lock()
@@ -336,7 +624,7 @@ func runPicker(iter *searchIter) (string, error) {
 			if ev.err != nil {
 				p.clear()
 				iter.Close()
-				return "", ev.err
+				return nil, ev.err
 			}
 			b := ev.b
 
@@ -344,7 +632,7 @@ func runPicker(iter *searchIter) (string, error) {
 			case len(b) == 1 && b[0] == 27: // Escape
 				p.clear()
 				iter.Close()
-				return "", nil
+				return nil, nil
 
 			case len(b) == 1 && b[0] == 13: // Enter
 				sel := p.getSelection()
@@ -352,6 +640,21 @@ func runPicker(iter *searchIter) (string, error) {
 				iter.Close()
 				return sel, nil
 
+			case len(b) == 1 && b[0] == 15: // Ctrl-O: the alternate locations
+				sel := p.getSelection()
+				for i, m := range sel {
+					if m.alt != nil {
+						sel[i] = *m.alt
+					}
+				}
+				p.clear()
+				iter.Close()
+				return sel, nil
+
+			case len(b) == 1 && b[0] == 9: // Tab
+				p.toggleMark()
+				redraw()
+
 			case len(b) == 1 && (b[0] == 127 || b[0] == 8): // Backspace
 				if len(search) > 0 {
 					search = search[:len(search)-1]
@@ -379,13 +682,22 @@ func runPicker(iter *searchIter) (string, error) {
 	}
 }
 
-func invokeEditor(path string) error {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
+// invokeEditor runs the editor command, which may include arguments, on
+// paths.
+func invokeEditor(editor string, paths ...string) error {
+	args := strings.Fields(editor)
+	if len(args) == 0 {
 		return fmt.Errorf("$EDITOR is not set; set it to your preferred editor (e.g., export EDITOR=vim)")
 	}
-	cmd := exec.Command(editor, path)
+	cmd := exec.Command(args[0], append(args[1:], paths...)...)
 	cmd.Stdin = os.Stdin
+	// If stdin was used for input (edit -f), give the editor the terminal.
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			defer tty.Close()
+			cmd.Stdin = tty
+		}
+	}
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFitLine(t *testing.T) {
+	tests := []struct {
+		dp, text string
+		width    int
+		wantDP   string
+		wantText string
+	}{
+		{"a/b.go", "text", 20, "a/b.go", "text"},
+		{"a/b.go", "long text", 12, "a/b.go", "lon…"},
+		{"a/b.go", "text", 9, "a/b.go", ""},
+		{"dir/sub/file.go", "", 10, "…b/file.go", ""},
+		{"dir/sub/file.go", "text", 10, "…b/file.go", ""},
+		{"ünïcödé/f.go", "", 8, "…dé/f.go", ""},
+	}
+	for _, tt := range tests {
+		dp, text := fitLine(tt.dp, tt.text, tt.width)
+		if dp != tt.wantDP || text != tt.wantText {
+			t.Errorf("fitLine(%q, %q, %d) = %q, %q, want %q, %q", tt.dp, tt.text, tt.width, dp, text, tt.wantDP, tt.wantText)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1024, "1.0K"},
+		{1536, "1.5K"},
+		{10 << 10, "10K"},
+		{1023 << 10, "1023K"},
+		{5 << 20, "5.0M"},
+		{3 << 30, "3.0G"},
+		{2048 << 40, "2048T"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Second, "0s ago"},
+		{59 * time.Second, "59s ago"},
+		{time.Minute, "1m ago"},
+		{90 * time.Minute, "1h ago"},
+		{day, "1d ago"},
+		{29 * day, "29d ago"},
+		{45 * day, "1mo ago"},
+		{400 * day, "1y ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestParseGitStatus(t *testing.T) {
+	out := " M mod.go\x00M  staged.go\x00R  new.go\x00old.go\x00?? dir/untracked.go\x00UU conflict.go\x00 D gone.go\x00"
+	top := filepath.FromSlash("/top")
+	want := map[string]byte{
+		filepath.Join(top, "mod.go"):           'M',
+		filepath.Join(top, "staged.go"):        'M',
+		filepath.Join(top, "new.go"):           'R',
+		filepath.Join(top, "dir/untracked.go"): '?',
+		filepath.Join(top, "conflict.go"):      'U',
+		filepath.Join(top, "gone.go"):          'D',
+	}
+	got := parseGitStatus(top, []byte(out))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseGitStatus = %q, want %q", got, want)
+	}
+}
+
+func TestGitStatusCache(t *testing.T) {
+	repo := initTestRepo(t, "a.go", "b.go")
+	writeFile(t, filepath.Join(repo, "a.go"), "changed\n")
+	c := newGitStatusCache()
+	a := filepath.Join(repo, "a.go")
+	if l := c.letter(a); l != ' ' {
+		t.Errorf("letter before loading = %q, want ' '", l)
+	}
+	c.load(a)
+	c.load(filepath.Join(repo, "b.go"))
+	select {
+	case <-c.ready:
+	case <-time.After(10 * time.Second):
+		t.Fatal("status never loaded")
+	}
+	if l := c.letter(a); l != 'M' {
+		t.Errorf("letter(a.go) = %q, want 'M'", l)
+	}
+	if l := c.letter(filepath.Join(repo, "b.go")); l != ' ' {
+		t.Errorf("letter(b.go) = %q, want ' '", l)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// quickfixRE matches a location line of compiler or linter output:
+//
+//	./main.go:10:2: undefined: x                    (go build)
+//	vet: ./main.go:293:31: too many arguments       (go vet)
+//	main.go:12:6: func f is unused (U1000)          (staticcheck)
+//	main.go:12:6: func `f` is unused (unused)       (golangci-lint)
+//	main.go:12: message                             (no column)
+//
+// The file may not contain spaces, which keeps prose containing colons
+// from matching.
+var quickfixRE = regexp.MustCompile(`^(?:vet: )?([^\s:]+):(\d+)(?::(\d+))?: *(.*)$`)
+
+// quickfixEntry is a location and message from compiler or linter output.
+type quickfixEntry struct {
+	file string // file, as printed
+	line int
+	col  int // 0 if not given
+	msg  string
+}
+
+// parseQuickfix extracts the locations in compiler or linter output from
+// r, in order. Indented lines following a location, such as go vet's
+// "have ... want ..." lines, continue its message. Other lines, and
+// repeated entries, are ignored.
+func parseQuickfix(r io.Reader) ([]quickfixEntry, error) {
+	var entries []quickfixEntry
+	seen := make(map[quickfixEntry]bool)
+	last := -1 // index of the entry continuation lines belong to
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		if strings.HasPrefix(text, "\t") && last >= 0 {
+			entries[last].msg += " " + strings.TrimSpace(text)
+			continue
+		}
+		last = -1
+		m := quickfixRE.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		e := quickfixEntry{file: m[1], msg: m[4]}
+		e.line, _ = strconv.Atoi(m[2])
+		e.col, _ = strconv.Atoi(m[3])
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		entries = append(entries, e)
+		last = len(entries) - 1
+	}
+	return entries, sc.Err()
+}
+
+// newQuickfixIter returns an iterator over the locations in the compiler
+// or linter output in r that can be found locally, described by their
+// messages. Relative paths are resolved against pwd first, then the
+// roots. Locations that can't be found are dropped.
+func newQuickfixIter(r io.Reader, pwd string, roots []string) (*searchIter, error) {
+	entries, err := parseQuickfix(r)
+	if err != nil {
+		return nil, err
+	}
+	res := newLocationResolver(pwd, roots)
+	var ms []match
+	for _, e := range entries {
+		if path := res.resolve(e.file); path != "" {
+			ms = append(ms, match{path: path, line: e.line, col: e.col, text: e.msg})
+		}
+	}
+	return newMatchIter(ms), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseQuickfix(t *testing.T) {
+	in := `# example.com/m
+./main.go:10:2: undefined: x
+vet: ./main.go:293:31: too many arguments in call to f
+	have (int, int)
+	want (int)
+main.go:12:6: func f is unused (U1000)
+sub/x.go:7: message without column
+./main.go:10:2: undefined: x
+note: see https://example.com:8080/doc for details
+FAIL	example.com/m [build failed]
+`
+	want := []quickfixEntry{
+		{"./main.go", 10, 2, "undefined: x"},
+		{"./main.go", 293, 31, "too many arguments in call to f have (int, int) want (int)"},
+		{"main.go", 12, 6, "func f is unused (U1000)"},
+		{"sub/x.go", 7, 0, "message without column"},
+	}
+	got, err := parseQuickfix(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseQuickfix =\n\t%v\nwant\n\t%v", got, want)
+	}
+}
+
+func TestMatchTarget(t *testing.T) {
+	tests := []struct {
+		m      match
+		suffix string
+		want   string
+	}{
+		{match{path: "/a/f.go"}, "", "/a/f.go"},
+		{match{path: "/a/f.go"}, ":12", "/a/f.go:12"},
+		{match{path: "/a/f.go", line: 3}, ":12", "/a/f.go:3"},
+		{match{path: "/a/f.go", line: 3, col: 7}, "", "/a/f.go:3:7"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.target(tt.suffix); got != tt.want {
+			t.Errorf("%+v.target(%q) = %q, want %q", tt.m, tt.suffix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// namedRoot is a search root, optionally named so that patterns can
+// address it alone as @name.
+type namedRoot struct {
+	name string
+	dir  string
+}
+
+// parseRoot parses a root entry from $EDITPATH or a config file, which is
+// either a directory or name=directory.
+func parseRoot(entry string) namedRoot {
+	name, dir, ok := strings.Cut(entry, "=")
+	if !ok || name == "" || strings.ContainsAny(name, "/~") {
+		return namedRoot{dir: entry}
+	}
+	return namedRoot{name: name, dir: dir}
+}
+
+// resolvePattern determines the roots to search for pattern and the
+// pattern to search for below them:
+//
+//   - an absolute pattern containing "..." is searched below its fixed
+//     directory prefix,
+//   - a pattern starting with "./" is searched in the current directory,
+//   - a pattern starting with "@name/" is searched in the root named name,
+//   - a pattern starting with "^/" is searched in the project root,
+//   - anything else is searched in each of the configured roots (see
+//     config), then in the current directory or, in project mode, first
+//     in the project root.
+func resolvePattern(pattern string, cfg *config) ([]string, string, error) {
+	if strings.HasPrefix(pattern, "/") {
+		root, rest := splitPattern(pattern)
+		return []string{root}, rest, nil
+	}
+	if root, rest, ok, err := splitScope(pattern, cfg); ok || err != nil {
+		if err != nil {
+			return nil, "", err
+		}
+		if rest == "" {
+			return nil, "", fmt.Errorf("pattern %s has nothing to search for below the root", pattern)
+		}
+		return []string{root}, rest, nil
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if strings.HasPrefix(pattern, "./") {
+		// Relative to pwd — use pwd as sole root
+		return []string{pwd}, strings.TrimPrefix(pattern, "./"), nil
+	}
+
+	// In project mode, the project root takes the place of pwd, ahead of
+	// the configured roots; pwd is below it.
+	if cfg.project {
+		if project := findProjectRoot(pwd, cfg.markers); project != "" {
+			return dedup(append([]string{project}, cfg.roots...)), pattern, nil
+		}
+	}
+
+	// Search the configured roots, including EDITPATH
+	roots := append([]string(nil), cfg.roots...)
+	// Append current directory implicitly
+	roots = append(roots, pwd)
+	// Resolve all roots to absolute paths and deduplicate
+	return dedup(roots), pattern, nil
+}
+
+// splitScope splits a pattern that addresses a single root, @name/rest or
+// ^/rest, into the root and the rest. It returns ok false for other
+// patterns, and an error if the root doesn't exist.
+func splitScope(pattern string, cfg *config) (root, rest string, ok bool, err error) {
+	if name, rest, ok := splitRootName(pattern); ok {
+		root, ok := cfg.rootNames[name]
+		if !ok {
+			return "", "", false, fmt.Errorf("unknown root @%s", name)
+		}
+		return root, rest, true, nil
+	}
+	if rest, ok := strings.CutPrefix(pattern, "^/"); ok {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return "", "", false, err
+		}
+		root := findProjectRoot(pwd, cfg.markers)
+		if root == "" {
+			return "", "", false, fmt.Errorf("no project root (containing %s) above %s", strings.Join(cfg.markers, ", "), pwd)
+		}
+		return root, rest, true, nil
+	}
+	return "", "", false, nil
+}
+
+// findProjectRoot returns the nearest directory at or above dir that
+// contains one of markers, or "" if there is none.
+func findProjectRoot(dir string, markers []string) string {
+	for {
+		for _, m := range markers {
+			if _, err := os.Stat(filepath.Join(dir, m)); err == nil {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// expandPattern expands $VAR and ${VAR} references and a leading ~ in
+// pattern, and makes a pattern starting with a ".." segment absolute,
+// relative to pwd. The fixed directory prefix of a resulting absolute
+// pattern is cleaned, so that it is searched like any other absolute
+// pattern.
+func expandPattern(pattern, pwd string) (string, error) {
+	var undefined string
+	pattern = os.Expand(pattern, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok && undefined == "" {
+			undefined = name
+		}
+		return v
+	})
+	if undefined != "" {
+		return "", fmt.Errorf("pattern refers to undefined variable $%s", undefined)
+	}
+	pattern = expandHome(pattern)
+	if pattern == ".." || strings.HasPrefix(pattern, "../") {
+		pattern = pwd + "/" + pattern
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return pattern, nil
+	}
+	if !strings.Contains(pattern, "...") {
+		return filepath.Clean(pattern), nil
+	}
+	root, rest := splitPattern(pattern)
+	return strings.TrimSuffix(root, "/") + "/" + rest, nil
+}
+
+// splitPattern splits an absolute pattern before its first segment
+// containing "...", returning the fixed directory prefix as a root and
+// the rest as the search pattern.
+func splitPattern(pattern string) (string, string) {
+	parts := strings.Split(pattern, "/")
+	splitAt := 0
+	for i, part := range parts {
+		if strings.Contains(part, "...") {
+			splitAt = i
+			break
+		}
+	}
+	root := filepath.Clean("/" + strings.Join(parts[:splitAt], "/"))
+	return root, strings.Join(parts[splitAt:], "/")
+}
+
+// splitRootName splits a pattern of the form @name/rest. A bare @name has
+// an empty rest.
+func splitRootName(pattern string) (name, rest string, ok bool) {
+	if !strings.HasPrefix(pattern, "@") {
+		return "", "", false
+	}
+	name, rest, _ = strings.Cut(pattern[1:], "/")
+	return name, rest, name != ""
+}
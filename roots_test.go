@@ -0,0 +1,247 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExpandPattern(t *testing.T) {
+	t.Setenv("HOME", "/home/u")
+	t.Setenv("PROJ", "/src/proj")
+	t.Setenv("EMPTY", "")
+	pwd := "/work/dir"
+	tests := []struct {
+		in, want string
+	}{
+		{"...go", "...go"},
+		{"cmd/main.go", "cmd/main.go"},
+		{"~/x.go", "/home/u/x.go"},
+		{"~", "/home/u"},
+		{"~/src/.../main.go", "/home/u/src/.../main.go"},
+		{"$PROJ/x.go", "/src/proj/x.go"},
+		{"${PROJ}/...go", "/src/proj/...go"},
+		{"$EMPTY/x.go", "/x.go"},
+		{"../x.go", "/work/x.go"},
+		{"..", "/work"},
+		{"../../.../y.go", "/.../y.go"},
+		{"/a/./b/../c.go", "/a/c.go"},
+		{"/a/../b/...go", "/b/...go"},
+		{"..x.go", "..x.go"},
+		{"~user/x.go", "~user/x.go"},
+	}
+	for _, tt := range tests {
+		got, err := expandPattern(tt.in, pwd)
+		if err != nil || got != tt.want {
+			t.Errorf("expandPattern(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := expandPattern("$NO_SUCH_VARIABLE_HERE/x.go", pwd); err == nil {
+		t.Errorf("expandPattern with an undefined variable: no error")
+	}
+}
+
+func TestSplitPattern(t *testing.T) {
+	tests := []struct {
+		in, root, rest string
+	}{
+		{"/src/proj/...go", "/src/proj", "...go"},
+		{"/src/.../main.go", "/src", ".../main.go"},
+		{"/src/cmd.../main.go", "/src", "cmd.../main.go"},
+		{"/...go", "/", "...go"},
+		{"/a/b/../c/...go", "/a/c", "...go"},
+	}
+	for _, tt := range tests {
+		root, rest := splitPattern(tt.in)
+		if root != tt.root || rest != tt.rest {
+			t.Errorf("splitPattern(%q) = %q, %q, want %q, %q", tt.in, root, rest, tt.root, tt.rest)
+		}
+	}
+}
+
+func TestParseRoot(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  namedRoot
+	}{
+		{"/src", namedRoot{dir: "/src"}},
+		{"go=/usr/local/go/src", namedRoot{name: "go", dir: "/usr/local/go/src"}},
+		{"=/src", namedRoot{dir: "=/src"}},
+		{"/a=b", namedRoot{dir: "/a=b"}},
+		{"~/x=y", namedRoot{dir: "~/x=y"}},
+		{"n=", namedRoot{name: "n", dir: ""}},
+	}
+	for _, tt := range tests {
+		if got := parseRoot(tt.entry); got != tt.want {
+			t.Errorf("parseRoot(%q) = %+v, want %+v", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRootName(t *testing.T) {
+	tests := []struct {
+		pattern, name, rest string
+		ok                  bool
+	}{
+		{"@go/net/...go", "go", "net/...go", true},
+		{"@go", "go", "", true},
+		{"@go/", "go", "", true},
+		{"@", "", "", false},
+		{"@/x", "", "x", false},
+		{"go/@x", "", "", false},
+	}
+	for _, tt := range tests {
+		name, rest, ok := splitRootName(tt.pattern)
+		if name != tt.name || rest != tt.rest || ok != tt.ok {
+			t.Errorf("splitRootName(%q) = %q, %q, %v, want %q, %q, %v", tt.pattern, name, rest, ok, tt.name, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestSplitScopeNamed(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.rootNames["go"] = "/usr/local/go/src"
+	tests := []struct {
+		pattern, root, rest string
+		ok, err             bool
+	}{
+		{"@go/net/...go", "/usr/local/go/src", "net/...go", true, false},
+		{"@go", "/usr/local/go/src", "", true, false},
+		{"@nope/x.go", "", "", false, true},
+		{"net/...go", "", "", false, false},
+	}
+	for _, tt := range tests {
+		root, rest, ok, err := splitScope(tt.pattern, cfg)
+		if root != tt.root || rest != tt.rest || ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("splitScope(%q) = %q, %q, %v, %v, want %q, %q, %v, error %v", tt.pattern, root, rest, ok, err, tt.root, tt.rest, tt.ok, tt.err)
+		}
+	}
+	if _, _, err := resolvePattern("@go", cfg); err == nil {
+		t.Errorf("resolvePattern(@go): no error for a pattern with nothing below the root")
+	}
+}
+
+func TestCompleteRootNames(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.rootNames["go"] = "/usr/local/go/src"
+	cfg.rootNames["gopath"] = "/home/u/go"
+	cfg.rootNames["work"] = "/work"
+	tests := []struct {
+		word string
+		want []string
+	}{
+		{"@", []string{"@go/", "@gopath/", "@work/"}},
+		{"@go", []string{"@go/", "@gopath/"}},
+		{"@w", []string{"@work/"}},
+		{"@x", nil},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		complete(&b, tt.word, cfg, searchOptions{})
+		if got := strings.Fields(b.String()); !slices.Equal(got, tt.want) {
+			t.Errorf("complete(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+// chdir changes to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	old, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(old) })
+}
+
+// projectTree creates a project with a nested module, and a directory
+// outside any project, in a temporary directory. It returns the directory
+// and the project markers, which are named so as not to exist above it.
+func projectTree(t *testing.T) (string, []string) {
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	markers := []string{".edit-test-git", "edit-test.mod"}
+	for _, f := range []string{
+		"proj/.edit-test-git/HEAD",
+		"proj/sub/deep/x.go",
+		"proj/sub/mod/edit-test.mod",
+		"proj/sub/mod/pkg/y.go",
+		"none/z.go",
+	} {
+		writeFile(t, filepath.Join(tmp, f), "")
+	}
+	return tmp, markers
+}
+
+func TestFindProjectRoot(t *testing.T) {
+	tmp, markers := projectTree(t)
+	tests := []struct {
+		dir, want string
+	}{
+		{"proj", "proj"},
+		{"proj/sub/deep", "proj"},
+		{"proj/sub/mod", "proj/sub/mod"},
+		{"proj/sub/mod/pkg", "proj/sub/mod"},
+		{"none", ""},
+	}
+	for _, tt := range tests {
+		want := ""
+		if tt.want != "" {
+			want = filepath.Join(tmp, tt.want)
+		}
+		if got := findProjectRoot(filepath.Join(tmp, tt.dir), markers); got != want {
+			t.Errorf("findProjectRoot(%s) = %q, want %q", tt.dir, got, want)
+		}
+	}
+}
+
+func TestResolvePatternProject(t *testing.T) {
+	tmp, markers := projectTree(t)
+	other := filepath.Join(tmp, "other")
+	tests := []struct {
+		pwd     string
+		project bool
+		pattern string
+		roots   []string
+		rest    string
+		err     string
+	}{
+		{"proj/sub/deep", false, "...go", []string{"other", "proj/sub/deep"}, "...go", ""},
+		{"proj/sub/deep", true, "...go", []string{"proj", "other"}, "...go", ""},
+		{"proj/sub/mod/pkg", true, "...go", []string{"proj/sub/mod", "other"}, "...go", ""},
+		{"none", true, "...go", []string{"other", "none"}, "...go", ""},
+		{"proj/sub/deep", false, "^/...go", []string{"proj"}, "...go", ""},
+		{"proj/sub/deep", true, "./...go", []string{"proj/sub/deep"}, "...go", ""},
+		{"proj/sub/deep", false, "^/", nil, "", "nothing to search for"},
+		{"none", false, "^/...go", nil, "", "no project root"},
+	}
+	for _, tt := range tests {
+		chdir(t, filepath.Join(tmp, tt.pwd))
+		cfg := newTestConfig()
+		cfg.markers = markers
+		cfg.project = tt.project
+		cfg.roots = []string{other}
+		roots, rest, err := resolvePattern(tt.pattern, cfg)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("in %s, resolvePattern(%q): error %v, want %q", tt.pwd, tt.pattern, err, tt.err)
+			}
+			continue
+		}
+		var want []string
+		for _, r := range tt.roots {
+			want = append(want, filepath.Join(tmp, r))
+		}
+		if err != nil || !slices.Equal(roots, want) || rest != tt.rest {
+			t.Errorf("in %s, project %v, resolvePattern(%q) = %q, %q, %v, want %q, %q",
+				tt.pwd, tt.project, tt.pattern, roots, rest, err, want, tt.rest)
+		}
+	}
+}
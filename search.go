@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 type segmentKind int
@@ -103,38 +104,106 @@ func parsePattern(pattern string) ([]segment, error) {
 	return segments, nil
 }
 
+// symlinkPolicy determines when the walker descends through symlinked
+// directories.
+type symlinkPolicy int
+
+const (
+	followExplicit symlinkPolicy = iota // only for exact pattern segments
+	followNever                         // never
+	followAlways                        // also while listing, with cycle detection
+)
+
+func parseSymlinkPolicy(s string) (symlinkPolicy, error) {
+	switch s {
+	case "explicit":
+		return followExplicit, nil
+	case "never":
+		return followNever, nil
+	case "always":
+		return followAlways, nil
+	}
+	return 0, fmt.Errorf("invalid symlink policy %q (want never, explicit or always)", s)
+}
+
+// match is a search result.
+type match struct {
+	path string // absolute path
+	root string // root it was found under, if any
+	line int    // line of interest, or 0
+	col  int    // column within line, or 0
+	text string // description, such as the function at line
+	alt  *match // alternate location, such as a failing test's declaration
+}
+
+// target returns the editor argument for m: its path and, if it has one,
+// its line and column, or otherwise the pattern's line suffix.
+func (m match) target(suffix string) string {
+	switch {
+	case m.line > 0 && m.col > 0:
+		return fmt.Sprintf("%s:%d:%d", m.path, m.line, m.col)
+	case m.line > 0:
+		return fmt.Sprintf("%s:%d", m.path, m.line)
+	}
+	return m.path + suffix
+}
+
 // searchIter is a pull-based iterator over file search results.
 // The consumer calls Next() to get results one at a time, providing
 // natural backpressure via the unbuffered channel.
 type searchIter struct {
-	ch          chan string  // unbuffered — backpressure
-	done        chan struct{}
-	once        sync.Once
+	ch    chan match // unbuffered — backpressure
+	done  chan struct{}
+	once  sync.Once
+	opts  searchOptions
+	stats *searchStats // nil unless statistics were requested
+	segs  []segment    // the parsed pattern, relative to each root
+
+	// Walker state, owned by the search goroutine.
+	root    string            // root currently being searched
+	path    []fileKey         // directories from the root to the current one, when tracked (see descend)
+	links   int               // symlinked directories listed on the way to the current one
+	seen    map[fileKey]bool  // files already emitted, when remembered (see emitFile)
+	fsTypes map[uint64]string // filesystem type by device
+}
+
+// searchOptions controls how a searchIter walks the filesystem.
+type searchOptions struct {
 	sortByMtime bool
+	follow      symlinkPolicy
+	xdev        bool            // don't descend into other filesystems
+	skipFS      []string        // filesystem types not to descend into
+	where       []filePredicate // conditions results must satisfy
+	dirs        bool            // match directories instead of files at the leaf
+	ignore      []string        // globs for names not to list
+	stats       *searchStats    // if non-nil, filesystem work is counted here
 }
 
 // newSearchIter parses the pattern, starts a search goroutine, and
 // returns an iterator. The caller must call Close() when done.
-func newSearchIter(roots []string, pattern string, sortByMtime bool) (*searchIter, error) {
+func newSearchIter(roots []string, pattern string, opts searchOptions) (*searchIter, error) {
 	segments, err := parsePattern(pattern)
 	if err != nil {
 		return nil, err
 	}
 
-	it := &searchIter{
-		ch:          make(chan string),
-		done:        make(chan struct{}),
-		sortByMtime: sortByMtime,
-	}
+	it := newIter(opts)
+	it.segs = segments
 
 	go func() {
 		defer close(it.ch)
+		defer it.stats.finish()
 		for _, root := range roots {
-			info, err := os.Stat(root)
+			it.root = root
+			it.stats.beginRoot(root)
+			info, err := it.stat(root)
 			if err != nil || !info.IsDir() {
 				continue
 			}
-			if !it.matchSegments(root, segments) {
+			ok := it.descend(root, func() bool {
+				return it.matchSegments(root, segments)
+			})
+			if !ok {
 				return // cancelled
 			}
 		}
@@ -143,16 +212,36 @@ func newSearchIter(roots []string, pattern string, sortByMtime bool) (*searchIte
 	return it, nil
 }
 
+// newIter returns an iterator with no goroutine feeding it yet.
+func newIter(opts searchOptions) *searchIter {
+	return &searchIter{
+		ch:      make(chan match),
+		done:    make(chan struct{}),
+		opts:    opts,
+		stats:   opts.stats,
+		seen:    make(map[fileKey]bool),
+		fsTypes: make(map[uint64]string),
+	}
+}
+
 // newSliceIter wraps a pre-collected list of files as a searchIter.
 func newSliceIter(files []string) *searchIter {
-	it := &searchIter{
-		ch:   make(chan string),
-		done: make(chan struct{}),
+	ms := make([]match, len(files))
+	for i, f := range files {
+		ms[i] = match{path: f}
 	}
+	return newMatchIter(ms)
+}
+
+// newMatchIter wraps pre-collected results as a searchIter.
+func newMatchIter(ms []match) *searchIter {
+	it := newIter(searchOptions{})
 	go func() {
 		defer close(it.ch)
-		for _, f := range files {
-			if !it.emit(f) {
+		for _, m := range ms {
+			select {
+			case it.ch <- m:
+			case <-it.done:
 				return
 			}
 		}
@@ -160,11 +249,53 @@ func newSliceIter(files []string) *searchIter {
 	return it
 }
 
+// newFallbackIter returns an iterator over the results of first or, if it
+// has none, over those of the iterator returned by fallback, which may be
+// nil.
+func newFallbackIter(first *searchIter, fallback func() *searchIter) *searchIter {
+	it := newIter(first.opts)
+	it.segs = first.segs
+	go func() {
+		defer close(it.ch)
+		found, ok := it.forward(first)
+		if found || !ok {
+			return
+		}
+		if next := fallback(); next != nil {
+			it.forward(next)
+		}
+	}()
+	return it
+}
+
+// forward sends the results of from to the consumer until from is
+// exhausted, reporting whether there were any and whether it wasn't
+// cancelled.
+func (it *searchIter) forward(from *searchIter) (found, ok bool) {
+	defer from.Close()
+	for {
+		select {
+		case m, more := <-from.ch:
+			if !more {
+				return found, true
+			}
+			select {
+			case it.ch <- m:
+				found = true
+			case <-it.done:
+				return found, false
+			}
+		case <-it.done:
+			return found, false
+		}
+	}
+}
+
 // Next returns the next result. It blocks until a result is available
-// or the iterator is exhausted. Returns ("", false) when done.
-func (it *searchIter) Next() (string, bool) {
-	path, ok := <-it.ch
-	return path, ok
+// or the iterator is exhausted. Returns false when done.
+func (it *searchIter) Next() (match, bool) {
+	m, ok := <-it.ch
+	return m, ok
 }
 
 // Close signals the search goroutine to stop.
@@ -176,7 +307,8 @@ func (it *searchIter) Close() {
 // false if the iterator was closed (cancelled).
 func (it *searchIter) emit(path string) bool {
 	select {
-	case it.ch <- path:
+	case it.ch <- match{path: path, root: it.root}:
+		it.stats.result()
 		return true
 	case <-it.done:
 		return false
@@ -205,10 +337,12 @@ func (it *searchIter) matchSegments(base string, segs []segment) bool {
 			return false
 		}
 		// Walk subdirectories (sorted lex), recurse with same ... + remaining
-		dirs := listDirs(base)
-		for _, d := range dirs {
-			sub := filepath.Join(base, d)
-			if !it.matchSegments(sub, segs) {
+		for _, d := range it.listDirs(base) {
+			sub := filepath.Join(base, d.Name())
+			ok := it.enter(sub, d, func() bool {
+				return it.matchSegments(sub, segs)
+			})
+			if !ok {
 				return false
 			}
 		}
@@ -216,26 +350,30 @@ func (it *searchIter) matchSegments(base string, segs []segment) bool {
 	case segWild:
 		if !strings.Contains(seg.pattern, "...") {
 			// Exact segment — use os.Stat directly (O(1) vs listing the directory).
+			// Symlinks are followed here unless the policy is never.
 			candidate := filepath.Join(base, seg.pattern)
-			info, err := os.Stat(candidate)
+			stat := it.stat
+			if it.opts.follow == followNever {
+				stat = it.lstat
+			}
+			info, err := stat(candidate)
 			if err != nil || !info.IsDir() {
 				return true
 			}
-			return it.matchSegments(candidate, rest)
+			return it.descend(candidate, func() bool {
+				return it.matchSegments(candidate, rest)
+			})
 		}
 
 		// Wildcard segment — list the directory and filter.
 		prefix, _ := wildPrefix(seg.pattern)
-		entries, err := os.ReadDir(base)
+		entries, err := it.readDir(base)
 		if err != nil {
 			return true
 		}
 		for _, e := range entries {
-			if !e.IsDir() {
-				continue
-			}
 			name := e.Name()
-			if strings.HasPrefix(name, ".") {
+			if strings.HasPrefix(name, ".") || it.ignored(name) {
 				continue
 			}
 			if prefix != "" && !strings.HasPrefix(name, prefix) {
@@ -244,8 +382,14 @@ func (it *searchIter) matchSegments(base string, segs []segment) bool {
 			if !matchWild(seg.pattern, name) {
 				continue
 			}
+			if !it.isDir(base, e) {
+				continue
+			}
 			sub := filepath.Join(base, name)
-			if !it.matchSegments(sub, rest) {
+			ok := it.enter(sub, e, func() bool {
+				return it.matchSegments(sub, rest)
+			})
+			if !ok {
 				return false
 			}
 		}
@@ -254,7 +398,8 @@ func (it *searchIter) matchSegments(base string, segs []segment) bool {
 	return true
 }
 
-// matchLeaf matches files in base against the leaf segment pattern.
+// matchLeaf matches files in base against the leaf segment pattern, or
+// directories if searching for directories.
 // Returns true to keep going, false if cancelled.
 func (it *searchIter) matchLeaf(base string, seg segment) bool {
 	if seg.kind == segRecursive {
@@ -264,79 +409,340 @@ func (it *searchIter) matchLeaf(base string, seg segment) bool {
 	if !strings.Contains(seg.pattern, "...") {
 		// Exact filename — use os.Stat directly.
 		candidate := filepath.Join(base, seg.pattern)
-		info, err := os.Stat(candidate)
-		if err != nil || info.IsDir() {
+		info, err := it.stat(candidate)
+		if err != nil || info.IsDir() != it.opts.dirs {
 			return true
 		}
-		return it.emit(candidate)
+		return it.emitFile(candidate, info, it.links > 0)
+	}
+
+	if it.opts.dirs {
+		return it.matchLeafDirs(base, seg)
 	}
 
 	// Wildcard leaf — list directory and filter.
 	prefix, _ := wildPrefix(seg.pattern)
-	entries, err := os.ReadDir(base)
+	entries, err := it.readDir(base)
 	if err != nil {
 		return true
 	}
 
-	var files []string
+	var files []leafFile
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
 		}
 		name := e.Name()
-		if strings.HasPrefix(name, ".") {
+		if strings.HasPrefix(name, ".") || it.ignored(name) {
 			continue
 		}
 		if prefix != "" && !strings.HasPrefix(name, prefix) {
 			continue
 		}
-		if matchWild(seg.pattern, name) {
-			files = append(files, filepath.Join(base, name))
+		if !matchWild(seg.pattern, name) {
+			continue
 		}
+		path := filepath.Join(base, name)
+		link := e.Type()&os.ModeSymlink != 0 || it.links > 0
+		var info os.FileInfo
+		if link || it.needInfo() {
+			info, err = it.fileInfo(path, e)
+			if err != nil || info.IsDir() {
+				// Dangling symlink, or a symlink to a directory.
+				continue
+			}
+		}
+		files = append(files, leafFile{path, info, link})
 	}
 
 	if len(files) == 0 {
 		return true
 	}
 
-	if it.sortByMtime {
-		sortByMtime(files)
+	if it.opts.sortByMtime {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].info.ModTime().After(files[j].info.ModTime())
+		})
 	} else {
-		sort.Strings(files)
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].path < files[j].path
+		})
 	}
 
 	for _, f := range files {
-		if !it.emit(f) {
+		if !it.emitFile(f.path, f.info, f.link) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLeafDirs matches directories in base against the wildcard leaf
+// segment pattern, in the same order as matchLeaf matches files.
+// Returns true to keep going, false if cancelled.
+func (it *searchIter) matchLeafDirs(base string, seg segment) bool {
+	var dirs []leafFile
+	for _, d := range it.listDirs(base) {
+		if !matchWild(seg.pattern, d.Name()) {
+			continue
+		}
+		path := filepath.Join(base, d.Name())
+		link := d.Type()&os.ModeSymlink != 0 || it.links > 0
+		var info os.FileInfo
+		if link || it.needInfo() {
+			var err error
+			if info, err = it.stat(path); err != nil {
+				continue
+			}
+		}
+		dirs = append(dirs, leafFile{path, info, link})
+	}
+
+	// listDirs lists by name already.
+	if it.opts.sortByMtime {
+		sort.SliceStable(dirs, func(i, j int) bool {
+			return dirs[i].info.ModTime().After(dirs[j].info.ModTime())
+		})
+	}
+	for _, d := range dirs {
+		if !it.emitFile(d.path, d.info, d.link) {
 			return false
 		}
 	}
 	return true
 }
 
-// listDirs returns sorted directory names within base, excluding hidden dirs.
-func listDirs(base string) []string {
-	entries, err := os.ReadDir(base)
+// leafFile is a candidate result along with its (symlink-resolved) info,
+// which is nil unless needed (see needInfo).
+type leafFile struct {
+	path string
+	info os.FileInfo
+	link bool // reached through a symlink
+}
+
+// needInfo reports whether every candidate needs its info, for sorting,
+// predicates or deduplication, rather than only those reached through
+// symlinks.
+func (it *searchIter) needInfo() bool {
+	if it.opts.sortByMtime || it.opts.follow == followAlways {
+		return true
+	}
+	for _, p := range it.opts.where {
+		if p.needInfo {
+			return true
+		}
+	}
+	return false
+}
+
+// emitFile emits path if it satisfies the predicates. A file is skipped
+// if the same file, identified by device and inode, was already emitted
+// by another path. When following all symlinks, any file may be reached
+// both through a link and by its real path, so every file is remembered;
+// otherwise only those reached through a symlink are, so that plain walks
+// don't accumulate keys.
+func (it *searchIter) emitFile(path string, info os.FileInfo, link bool) bool {
+	for _, p := range it.opts.where {
+		if !p.test(path, info) {
+			return true
+		}
+	}
+	if link || it.opts.follow == followAlways {
+		if key, ok := fileKeyOf(info); ok {
+			if it.seen[key] {
+				return true
+			}
+			it.seen[key] = true
+		}
+	}
+	return it.emit(path)
+}
+
+// fileInfo returns the info for the entry e in its directory, following
+// a symlink to its target.
+func (it *searchIter) fileInfo(path string, e os.DirEntry) (os.FileInfo, error) {
+	if e.Type()&os.ModeSymlink != 0 {
+		return it.stat(path)
+	}
+	it.stats.stat(1)
+	return e.Info()
+}
+
+// isDir reports whether the walker should treat the entry e of base as a
+// directory to descend into, which for symlinks depends on the policy.
+func (it *searchIter) isDir(base string, e os.DirEntry) bool {
+	if e.IsDir() {
+		return true
+	}
+	if e.Type()&os.ModeSymlink == 0 || it.opts.follow != followAlways {
+		return false
+	}
+	info, err := it.stat(filepath.Join(base, e.Name()))
+	return err == nil && info.IsDir()
+}
+
+// enter descends into the directory sub listed as the entry e, counting
+// it while fn runs if it is a symlink.
+func (it *searchIter) enter(sub string, e os.DirEntry, fn func() bool) bool {
+	if e.Type()&os.ModeSymlink != 0 {
+		it.links++
+		defer func() { it.links-- }()
+	}
+	return it.descend(sub, fn)
+}
+
+// descend calls fn to walk dir, a directory below the current position.
+// When following all symlinks or policing filesystem boundaries, it
+// tracks the directories from the root down by device and inode. A
+// directory already among them is a symlink cycle, which would
+// otherwise be walked forever, and is skipped, as is a directory on
+// another filesystem when that is not allowed.
+func (it *searchIter) descend(dir string, fn func() bool) bool {
+	o := &it.opts
+	if o.follow != followAlways && !o.xdev && len(o.skipFS) == 0 {
+		return fn()
+	}
+	// Check the mount table first where we have one, so that a skipped
+	// mount is never touched: stat on a hung network mount hangs too.
+	// Below the root, any mount point is another filesystem.
+	if (o.xdev || len(o.skipFS) > 0) && len(it.path) > 0 {
+		if fstype, ok := mountType(dir); ok && (o.xdev || it.skipType(fstype)) {
+			return true
+		}
+	}
+	info, err := it.stat(dir)
+	if err != nil {
+		return true
+	}
+	key, ok := fileKeyOf(info)
+	if !ok {
+		return fn()
+	}
+	if n := len(it.path); n > 0 {
+		if o.xdev && key.dev != it.path[0].dev {
+			return true
+		}
+		if key.dev != it.path[n-1].dev && it.skipFilesystem(dir, key.dev) {
+			return true
+		}
+	}
+	for _, k := range it.path {
+		if k == key {
+			return true
+		}
+	}
+	it.path = append(it.path, key)
+	defer func() { it.path = it.path[:len(it.path)-1] }()
+	return fn()
+}
+
+// skipFilesystem reports whether dir, the root of a filesystem mounted
+// on device dev, has a type listed in the skip list. Types are looked up
+// once per device.
+func (it *searchIter) skipFilesystem(dir string, dev uint64) bool {
+	if len(it.opts.skipFS) == 0 {
+		return false
+	}
+	fstype, ok := it.fsTypes[dev]
+	if !ok {
+		fstype = fsType(dir)
+		it.fsTypes[dev] = fstype
+	}
+	return it.skipType(fstype)
+}
+
+// fsFamilies maps the names of versions of filesystems, as the mount
+// table and statfs report them, to the filesystem's usual name.
+var fsFamilies = map[string]string{
+	"nfs4":    "nfs",
+	"smb":     "cifs",
+	"smb2":    "cifs",
+	"smb3":    "cifs",
+	"smbfs":   "cifs",
+	"cgroup2": "cgroup",
+}
+
+// skipType reports whether the filesystem type is in the skip list. An
+// entry without a subtype, like "fuse", also matches all its subtypes,
+// like "fuse.sshfs", and an entry naming a filesystem, like "nfs", all
+// its versions, like "nfs4".
+func (it *searchIter) skipType(fstype string) bool {
+	if fstype == "" {
+		return false
+	}
+	family := fsFamilies[fstype]
+	for _, t := range it.opts.skipFS {
+		if t == fstype || t == family || strings.HasPrefix(fstype, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// readDir lists dir, counting the work in it.stats.
+func (it *searchIter) readDir(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	it.stats.readDir(len(entries))
+	return entries, err
+}
+
+// stat calls os.Stat, counting the call in it.stats.
+func (it *searchIter) stat(path string) (os.FileInfo, error) {
+	it.stats.stat(1)
+	return os.Stat(path)
+}
+
+// lstat calls os.Lstat, counting the call in it.stats.
+func (it *searchIter) lstat(path string) (os.FileInfo, error) {
+	it.stats.stat(1)
+	return os.Lstat(path)
+}
+
+// ignored reports whether name matches one of the ignore globs. Like
+// hidden names, ignored names are skipped when listing directories, but
+// can still be named exactly in a pattern.
+func (it *searchIter) ignored(name string) bool {
+	for _, glob := range it.opts.ignore {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listDirs returns the directory entries within base, sorted by name,
+// excluding hidden and ignored dirs.
+func (it *searchIter) listDirs(base string) []os.DirEntry {
+	entries, err := it.readDir(base)
 	if err != nil {
 		return nil
 	}
-	var dirs []string
+	var dirs []os.DirEntry
 	for _, e := range entries {
-		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
-			dirs = append(dirs, e.Name())
+		if !strings.HasPrefix(e.Name(), ".") && !it.ignored(e.Name()) && it.isDir(base, e) {
+			dirs = append(dirs, e)
 		}
 	}
-	sort.Strings(dirs)
+	// os.ReadDir already sorts by name.
 	return dirs
 }
 
 // sortByMtime sorts file paths by modification time, newest first.
+// Each file is stat'ed once; pairs involving a file that cannot be
+// stat'ed compare lexically.
 func sortByMtime(files []string) {
+	mtimes := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			mtimes[f] = info.ModTime()
+		}
+	}
 	sort.Slice(files, func(i, j int) bool {
-		si, ei := os.Stat(files[i])
-		sj, ej := os.Stat(files[j])
-		if ei != nil || ej != nil {
+		ti, oki := mtimes[files[i]]
+		tj, okj := mtimes[files[j]]
+		if !oki || !okj {
 			return files[i] < files[j]
 		}
-		return si.ModTime().After(sj.ModTime())
+		return ti.After(tj)
 	})
 }
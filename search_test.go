@@ -0,0 +1,197 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// searchRel runs a search for pattern below root and returns the results
+// relative to root.
+func searchRel(t *testing.T, root, pattern string, opts searchOptions) []string {
+	t.Helper()
+	it, err := newSearchIter([]string{root}, pattern, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var got []string
+	for m, ok := it.Next(); ok; m, ok = it.Next() {
+		rel, err := filepath.Rel(root, m.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	return got
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
+
+func TestSearchSymlinks(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "real/x.go"), "")
+	writeFile(t, filepath.Join(root, "real/sub/y.go"), "")
+	symlink(t, "real", filepath.Join(root, "link"))
+	// A cycle: real/sub/loop is real again.
+	symlink(t, "..", filepath.Join(root, "real/sub/loop"))
+
+	tests := []struct {
+		follow  symlinkPolicy
+		pattern string
+		want    []string
+	}{
+		{followNever, "...go", []string{"real/x.go", "real/sub/y.go"}},
+		{followNever, "link/...go", nil},
+		{followExplicit, "...go", []string{"real/x.go", "real/sub/y.go"}},
+		{followExplicit, "link/...go", []string{"link/x.go", "link/sub/y.go"}},
+		{followExplicit, "real/sub/loop/x.go", []string{"real/sub/loop/x.go"}},
+		// Each file once, however many links lead to it, and the cycle
+		// isn't walked.
+		{followAlways, "...go", []string{"link/x.go", "link/sub/y.go"}},
+		{followAlways, "real/...go", []string{"real/x.go", "real/sub/y.go"}},
+	}
+	for _, tt := range tests {
+		got := searchRel(t, root, tt.pattern, searchOptions{follow: tt.follow})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("follow %d, %s = %q, want %q", tt.follow, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// A file linked to directly is listed once when following all symlinks,
+// and under both names otherwise.
+func TestSearchSymlinkedFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "a.go"), "")
+	symlink(t, "a.go", filepath.Join(root, "b.go"))
+
+	for _, tt := range []struct {
+		follow symlinkPolicy
+		want   []string
+	}{
+		{followExplicit, []string{"a.go", "b.go"}},
+		{followAlways, []string{"a.go"}},
+	} {
+		got := searchRel(t, root, "...go", searchOptions{follow: tt.follow})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("follow %d = %q, want %q", tt.follow, got, tt.want)
+		}
+	}
+}
+
+func TestSkipType(t *testing.T) {
+	it := newIter(searchOptions{skipFS: []string{"nfs", "fuse", "cifs"}})
+	tests := []struct {
+		fstype string
+		want   bool
+	}{
+		{"nfs", true},
+		{"nfs4", true},
+		{"fuse", true},
+		{"fuse.sshfs", true},
+		{"smb3", true},
+		{"fuseblk", false},
+		{"ext4", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := it.skipType(tt.fstype); got != tt.want {
+			t.Errorf("skipType(%q) = %v, want %v", tt.fstype, got, tt.want)
+		}
+	}
+}
+
+// Under -xdev, a mount point below the root is skipped by the mount table
+// alone.
+func TestDescendMountPoint(t *testing.T) {
+	const dir = "/proc"
+	if _, ok := mountType(dir); !ok {
+		t.Skipf("%s is not in the mount table", dir)
+	}
+	it := newIter(searchOptions{xdev: true, stats: newSearchStats()})
+	it.stats.beginRoot("/")
+	it.path = []fileKey{{}} // below a root
+	walked := false
+	it.descend(dir, func() bool { walked = true; return true })
+	if walked {
+		t.Errorf("descended into %s", dir)
+	}
+	if n := it.stats.cur.stats; n != 0 {
+		t.Errorf("%d stat calls, want none", n)
+	}
+}
+
+func TestSearchDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"aa/x.go", "bb/sub/y.go", "bb/.hidden/z.go", "cc.go"} {
+		writeFile(t, filepath.Join(root, f), "")
+	}
+	now := time.Now()
+	for dir, age := range map[string]time.Duration{"aa": 2 * time.Hour, "bb": time.Hour, "bb/sub": 3 * time.Hour} {
+		mtime := now.Add(-age)
+		if err := os.Chtimes(filepath.Join(root, dir), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		pattern string
+		mtime   bool
+		want    []string
+	}{
+		{"...", false, []string{"aa", "bb", "bb/sub"}},
+		{"...", true, []string{"bb", "aa", "bb/sub"}},
+		{"b...", false, []string{"bb"}},
+		{"bb/...", false, []string{"bb/sub"}},
+		{"aa", false, []string{"aa"}},
+		{"cc.go", false, nil},
+	}
+	for _, tt := range tests {
+		got := searchRel(t, root, tt.pattern, searchOptions{dirs: true, sortByMtime: tt.mtime})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-d %s (mtime %v) = %q, want %q", tt.pattern, tt.mtime, got, tt.want)
+		}
+	}
+}
+
+// Filtering by type alone doesn't stat each file; predicates on the
+// file's info do.
+func TestSearchPredicateStats(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"a.go", "b.txt", "sub/c.go"} {
+		writeFile(t, filepath.Join(root, f), "")
+	}
+	byType, err := typePredicate([]string{"go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bySize, err := parsePredicate("size<1k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		where []filePredicate
+		stats int // besides the root's
+	}{
+		{[]filePredicate{byType}, 0},
+		{[]filePredicate{bySize}, 3},
+		{[]filePredicate{byType, bySize}, 3},
+	}
+	for _, tt := range tests {
+		opts := searchOptions{where: tt.where, stats: newSearchStats()}
+		got := searchRel(t, root, "...", opts)
+		if len(got) == 0 {
+			t.Errorf("no results")
+		}
+		if n := opts.stats.roots[0].stats - 1; n != tt.stats {
+			t.Errorf("%d predicates: %d stat calls, want %d", len(tt.where), n, tt.stats)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//go:embed shell/edit.bash shell/edit.zsh shell/edit.fish
+var shellScripts embed.FS
+
+// printShellScript writes the integration script for the named shell to
+// w, with the flag list filled in from this binary's flags.
+func printShellScript(w io.Writer, shell string) error {
+	script, err := shellScripts.ReadFile("shell/edit." + shell)
+	if err != nil {
+		return fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
+
+	var flags strings.Builder
+	flag.VisitAll(func(f *flag.Flag) {
+		switch shell {
+		case "fish":
+			opt := "-o"
+			if len(f.Name) == 1 {
+				opt = "-s"
+			}
+			_, usage := flag.UnquoteUsage(f)
+			fmt.Fprintf(&flags, "complete -c edit %s %s -d %s\n", opt, f.Name, fishQuote(usage))
+		default:
+			if flags.Len() > 0 {
+				flags.WriteByte(' ')
+			}
+			flags.WriteString("-" + f.Name)
+		}
+	})
+
+	_, err = io.WriteString(w, strings.Replace(string(script), "@FLAGS@", strings.TrimSuffix(flags.String(), "\n"), 1))
+	return err
+}
+
+// fishQuote quotes s as a single-quoted fish string.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+const (
+	completeMax    = 50                     // matches offered per completion
+	completeBudget = 200 * time.Millisecond // time spent searching per completion
+)
+
+// complete writes matches for the partially typed pattern word to w, one
+// per line, for the shell integration. Matches are searched for as
+// typed, then with a trailing "..." to complete a partial leaf. The
+// search runs under a tight budget to keep completion responsive;
+// whatever was found in time is written. Words without "..." are left to
+// the shell's own file completion.
+func complete(w io.Writer, word string, cfg *config, opts searchOptions) {
+	// A partial @name, even a bare @, completes to the named roots.
+	if name, ok := strings.CutPrefix(word, "@"); ok && !strings.Contains(word, "/") {
+		for _, n := range sortedKeys(cfg.rootNames) {
+			if strings.HasPrefix(n, name) {
+				fmt.Fprintf(w, "@%s/\n", n)
+			}
+		}
+		return
+	}
+	if !strings.Contains(word, "...") {
+		return
+	}
+	pwd, _ := os.Getwd()
+	expanded, err := expandPattern(word, pwd)
+	if err != nil {
+		return
+	}
+	// Paths below a single root are completed in @name/ or ^/ form.
+	var scope string
+	if _, rest, ok, _ := splitScope(word, cfg); ok {
+		scope = strings.TrimSuffix(word, rest)
+	}
+	deadline := time.After(completeBudget)
+	patterns := []string{expanded}
+	if !strings.HasSuffix(expanded, "...") {
+		patterns = append(patterns, expanded+"...")
+	}
+
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		roots, searchPattern, err := resolvePattern(pattern, cfg)
+		if err != nil {
+			return
+		}
+		iter, err := newSearchIter(roots, searchPattern, opts)
+		if err != nil {
+			return
+		}
+	collect:
+		for len(seen) < completeMax {
+			select {
+			case m, ok := <-iter.ch:
+				if !ok {
+					break collect
+				}
+				path := m.path
+				if seen[path] {
+					continue
+				}
+				seen[path] = true
+				if scope != "" {
+					if rel, err := filepath.Rel(m.root, path); err == nil {
+						path = scope + rel
+					}
+				} else if rel, err := filepath.Rel(pwd, path); err == nil && (!strings.HasPrefix(rel, "..") || strings.HasPrefix(word, "..")) {
+					path = rel
+				}
+				fmt.Fprintln(w, path)
+			case <-deadline:
+				iter.Close()
+				return
+			}
+		}
+		iter.Close()
+	}
+}
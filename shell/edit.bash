@@ -0,0 +1,63 @@
+# bash integration for edit. Load it from .bashrc with
+#
+#	eval "$(edit -shell bash)"
+#
+# It provides:
+#
+#	completion   patterns containing ... complete to real matches
+#	Ctrl-T       pick a file and insert it into the command line; a
+#	             pattern before the cursor narrows the search
+#	Alt-C        pick a directory and cd into it
+#	ecd PATTERN  cd into the first directory matching PATTERN
+#	             (ecd -a PATTERN picks one)
+
+__edit_flags='@FLAGS@'
+
+_edit_complete() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	COMPREPLY=()
+	if [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "$__edit_flags" -- "$cur"))
+	elif [[ $cur == *...* ]]; then
+		local IFS=$'\n'
+		COMPREPLY=($(edit -complete "$cur" 2>/dev/null))
+	fi
+}
+complete -o bashdefault -o default -o filenames -F _edit_complete edit
+
+__edit_widget() {
+	local left=${READLINE_LINE:0:READLINE_POINT} right=${READLINE_LINE:READLINE_POINT}
+	local word=${left##*[[:space:]]} pattern=... sel
+	if [[ $word == *...* ]]; then
+		pattern=$word
+	fi
+	sel=$(edit -a -p "$pattern") || return
+	[[ -n $sel ]] || return
+	if [[ $pattern != ... ]]; then
+		left=${left%"$word"}
+	fi
+	# Tab marks several paths, one per line; quote each.
+	local -a paths
+	mapfile -t paths <<<"$sel"
+	sel=$(printf '%q ' "${paths[@]}")
+	sel=${sel% }
+	READLINE_LINE=$left$sel$right
+	READLINE_POINT=$((${#left} + ${#sel}))
+}
+
+# Of several directories marked with Tab, the cd widget and ecd take the
+# first.
+__edit_cd_widget() {
+	local dir
+	dir=$(edit -a -p -d ...) && dir=${dir%%$'\n'*} && [[ -n $dir ]] && builtin cd -- "$dir"
+}
+
+if [[ $- == *i* ]]; then
+	bind -x '"\C-t": __edit_widget'
+	bind -x '"\ec": __edit_cd_widget'
+fi
+
+ecd() {
+	local dir
+	dir=$(edit -d -p "$@") && builtin cd -- "${dir%%$'\n'*}"
+}
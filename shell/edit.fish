@@ -0,0 +1,57 @@
+# fish integration for edit. Load it from config.fish with
+#
+#	edit -shell fish | source
+#
+# It provides:
+#
+#	completion   patterns containing ... complete to real matches
+#	Ctrl-T       pick a file and insert it into the command line; a
+#	             pattern under the cursor narrows the search
+#	Alt-C        pick a directory and cd into it
+#	ecd PATTERN  cd into the first directory matching PATTERN
+#	             (ecd -a PATTERN picks one)
+
+@FLAGS@
+
+function __edit_is_pattern
+	string match -q -- '*...*' (commandline -ct)
+end
+
+complete -c edit -n __edit_is_pattern -f -a '(edit -complete (commandline -ct) 2>/dev/null)'
+
+function __edit_widget
+	set -l tok (commandline -t)
+	set -l pattern ...
+	if string match -q -- '*...*' $tok
+		set pattern $tok
+	end
+	# Tab marks several paths, one per line; quote each.
+	set -l sel (edit -a -p $pattern)
+	if test -n "$sel"
+		set -l args (string join ' ' -- (string escape -- $sel))
+		if test "$pattern" = ...
+			commandline -i -- $args
+		else
+			commandline -t -- $args
+		end
+	end
+	commandline -f repaint
+end
+
+# Of several directories marked with Tab, the cd widget and ecd take the
+# first.
+function __edit_cd_widget
+	set -l dir (edit -a -p -d ...)
+	if test -n "$dir"
+		builtin cd -- $dir[1]
+	end
+	commandline -f repaint
+end
+
+bind \ct __edit_widget
+bind \ec __edit_cd_widget
+
+function ecd
+	set -l dir (edit -d -p $argv)
+	and builtin cd -- $dir[1]
+end
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// searchStats records the filesystem work done by a searchIter, both in
+// total and broken down by root. The search goroutine updates it while
+// the consumer may report it at any time, so all access is locked. All
+// methods are no-ops on a nil *searchStats, so the walker can count
+// unconditionally.
+type searchStats struct {
+	mu    sync.Mutex
+	start time.Time
+	first time.Duration // time to first result; zero if none yet
+	total time.Duration // set when the search finishes
+	roots []*rootStats
+	cur   *rootStats
+}
+
+// rootStats counts the work done under a single search root.
+type rootStats struct {
+	root    string
+	dirs    int // directories read
+	entries int // directory entries examined
+	stats   int // stat calls
+	results int // results emitted
+	elapsed time.Duration
+	begun   time.Time
+}
+
+func newSearchStats() *searchStats {
+	return &searchStats{start: time.Now()}
+}
+
+// beginRoot starts attributing work to root, closing out the previous one.
+func (s *searchStats) beginRoot(root string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.endRoot(now)
+	s.cur = &rootStats{root: root, begun: now}
+	s.roots = append(s.roots, s.cur)
+}
+
+// endRoot closes out the current root. Must be called with s.mu held.
+func (s *searchStats) endRoot(now time.Time) {
+	if s.cur != nil {
+		s.cur.elapsed = now.Sub(s.cur.begun)
+		s.cur = nil
+	}
+}
+
+// finish marks the search as complete.
+func (s *searchStats) finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.endRoot(now)
+	s.total = now.Sub(s.start)
+}
+
+// current returns the root being counted, creating an anonymous one for
+// work done outside any root. Must be called with s.mu held.
+func (s *searchStats) current() *rootStats {
+	if s.cur == nil {
+		s.cur = &rootStats{begun: time.Now()}
+		s.roots = append(s.roots, s.cur)
+	}
+	return s.cur
+}
+
+// readDir counts one directory read yielding n entries.
+func (s *searchStats) readDir(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.current()
+	r.dirs++
+	r.entries += n
+}
+
+// stat counts n stat calls.
+func (s *searchStats) stat(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current().stats += n
+}
+
+// result counts one result delivered to the consumer.
+func (s *searchStats) result() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.first == 0 {
+		s.first = time.Since(s.start)
+	}
+	s.current().results++
+}
+
+// report writes a summary followed by a per-root breakdown to w. If the
+// search is still running (e.g. it was cancelled after the first match),
+// times are measured up to now.
+func (s *searchStats) report(w io.Writer) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	total := s.total
+	if total == 0 {
+		total = now.Sub(s.start)
+	}
+	var sum rootStats
+	for _, r := range s.roots {
+		sum.dirs += r.dirs
+		sum.entries += r.entries
+		sum.stats += r.stats
+		sum.results += r.results
+	}
+	first := "none"
+	if s.first != 0 {
+		first = roundDuration(s.first).String()
+	}
+	fmt.Fprintf(w, "edit: %s; first result %s, total %s\n",
+		formatCounts(&sum), first, roundDuration(total))
+	for _, r := range s.roots {
+		elapsed := r.elapsed
+		if r == s.cur {
+			elapsed = now.Sub(r.begun)
+		}
+		root := r.root
+		if root == "" {
+			root = "(no root)"
+		}
+		fmt.Fprintf(w, "  %s: %s; %s\n", root, formatCounts(r), roundDuration(elapsed))
+	}
+}
+
+func formatCounts(r *rootStats) string {
+	return fmt.Sprintf("%d dirs, %d entries, %d stats, %d results",
+		r.dirs, r.entries, r.stats, r.results)
+}
+
+// roundDuration rounds d to a precision that is useful for reporting.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// traceFrameRE matches the location line of a frame in a Go stack trace,
+// which follows the line naming the function:
+//
+//	main.main()
+//		/home/gopher/src/hello/main.go:7 +0x25
+var traceFrameRE = regexp.MustCompile(`^\s+(\S.*\.go):(\d+)(?: \+0x[0-9a-f]+)?\s*$`)
+
+// traceFrame is a frame of a Go stack trace.
+type traceFrame struct {
+	fn   string // function, as printed
+	file string // file, as printed
+	line int
+}
+
+// parseTrace extracts the frames of the Go panics, goroutine dumps or
+// runtime/debug.Stack output in r, in order. Other lines are ignored.
+func parseTrace(r io.Reader) ([]traceFrame, error) {
+	var frames []traceFrame
+	prev := ""
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		if m := traceFrameRE.FindStringSubmatch(text); m != nil {
+			line, _ := strconv.Atoi(m[2])
+			frames = append(frames, traceFrame{fn: traceFunc(prev), file: m[1], line: line})
+		}
+		prev = text
+	}
+	return frames, sc.Err()
+}
+
+// traceFunc returns the function named by the line preceding a frame's
+// location, without its arguments.
+func traceFunc(line string) string {
+	line = strings.TrimSpace(line)
+	if rest, ok := strings.CutPrefix(line, "created by "); ok {
+		fn, _, _ := strings.Cut(rest, " in goroutine ")
+		return "created by " + fn
+	}
+	// Arguments are in the last parenthesised group; the function name
+	// itself may contain parentheses, as in main.(*T).m(...).
+	if i := strings.LastIndex(line, "("); i > 0 && strings.HasSuffix(line, ")") {
+		line = line[:i]
+	}
+	return line
+}
+
+// locationResolver maps the files named in stack traces and tool output,
+// which may come from another machine, a -trimpath build or the module
+// cache, or be relative to another directory, to local files.
+type locationResolver struct {
+	imports *goImportResolver
+	pwd     string   // directory relative paths are taken relative to first
+	roots   []string // directories to find trailing parts of unknown paths in
+}
+
+func newLocationResolver(pwd string, roots []string) *locationResolver {
+	r := &locationResolver{imports: newGoImportResolver(pwd), pwd: pwd}
+	r.roots = append(append([]string(nil), roots...), pwd)
+	if r.imports.env.goroot != "" {
+		r.roots = append(r.roots, filepath.Join(r.imports.env.goroot, "src"))
+	}
+	r.roots = dedup(r.roots)
+	return r
+}
+
+// resolve returns the local file for a file named in a trace or tool
+// output, or "" if there is none:
+//
+//   - an existing file is used as is, relative to pwd if it is relative,
+//   - a module cache path (.../pkg/mod/example.com/m@v1.2.3/f.go) or a
+//     -trimpath one (example.com/m@v1.2.3/f.go) is looked up in the
+//     workspace, then in the local module cache,
+//   - another -trimpath path (example.com/m/f.go, runtime/proc.go) is
+//     resolved as an import path,
+//   - and otherwise the longest trailing part of the path, of at least
+//     two elements, found below one of the roots is used.
+func (r *locationResolver) resolve(file string) string {
+	p := file
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(r.pwd, p)
+	}
+	if isFile(p) {
+		return p
+	}
+	rest := file
+	if i := strings.LastIndex(file, "/pkg/mod/"); i >= 0 {
+		rest = file[i+len("/pkg/mod/"):]
+	}
+	if mod, sub, ok := splitModuleVersion(rest); ok {
+		if d := r.imports.dir(mod); d != "" && isFile(filepath.Join(d, sub)) {
+			return filepath.Join(d, sub)
+		}
+		if p := filepath.Join(r.imports.env.modcache, rest); r.imports.env.modcache != "" && isFile(p) {
+			return p
+		}
+	} else if !filepath.IsAbs(file) {
+		if d := r.imports.dir(filepath.Dir(file)); d != "" && isFile(filepath.Join(d, filepath.Base(file))) {
+			return filepath.Join(d, filepath.Base(file))
+		}
+	}
+	return r.findSuffix(file)
+}
+
+// findSuffix looks for the longest trailing part of file below each root
+// in turn, down to its directory and base name: a checkout on another
+// machine may be in a directory of a different name. A base name alone,
+// unless that is all file is, would match unrelated files too readily.
+func (r *locationResolver) findSuffix(file string) string {
+	elems := strings.Split(strings.Trim(filepath.ToSlash(file), "/"), "/")
+	for i := 0; i < max(len(elems)-1, 1); i++ {
+		suffix := filepath.FromSlash(strings.Join(elems[i:], "/"))
+		for _, root := range r.roots {
+			if p := filepath.Join(root, suffix); isFile(p) {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// splitModuleVersion splits a module cache path of the form
+// escaped/module/path@version/sub/file.go into the module path and the
+// path within the module.
+func splitModuleVersion(path string) (mod, sub string, ok bool) {
+	at := strings.Index(path, "@")
+	if at < 0 {
+		return "", "", false
+	}
+	_, sub, ok = strings.Cut(path[at:], "/")
+	if !ok {
+		return "", "", false
+	}
+	return unescapeModulePath(path[:at]), sub, true
+}
+
+// unescapeModulePath reverses escapeModulePath.
+func unescapeModulePath(path string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range path {
+		if r == '!' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// newTraceIter returns an iterator over the frames of the stack traces
+// in r that can be found locally, each at its line and described by its
+// function. Frames that can't be found are dropped.
+func newTraceIter(r io.Reader, pwd string, roots []string) (*searchIter, error) {
+	frames, err := parseTrace(r)
+	if err != nil {
+		return nil, err
+	}
+	res := newLocationResolver(pwd, roots)
+	var ms []match
+	for _, f := range frames {
+		if path := res.resolve(f.file); path != "" {
+			ms = append(ms, match{path: path, line: f.line, text: f.fn})
+		}
+	}
+	return newMatchIter(ms), nil
+}
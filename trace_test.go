@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTrace(t *testing.T) {
+	in := `panic: boom
+
+goroutine 1 [running]:
+main.(*server).handle(0xc000010000, {0x1, 0x2})
+	/home/ci/src/app/server.go:42 +0x1d
+main.main()
+	/home/ci/src/app/main.go:7 +0x25
+created by net/http.(*Server).Serve in goroutine 1
+	/usr/local/go/src/net/http/server.go:3285 +0x4b4
+runtime/debug.Stack()
+	example.com/m@v1.2.3/debug.go:24
+exit status 2
+`
+	want := []traceFrame{
+		{"main.(*server).handle", "/home/ci/src/app/server.go", 42},
+		{"main.main", "/home/ci/src/app/main.go", 7},
+		{"created by net/http.(*Server).Serve", "/usr/local/go/src/net/http/server.go", 3285},
+		{"runtime/debug.Stack", "example.com/m@v1.2.3/debug.go", 24},
+	}
+	got, err := parseTrace(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTrace =\n\t%v\nwant\n\t%v", got, want)
+	}
+}
+
+func TestSplitModuleVersion(t *testing.T) {
+	tests := []struct {
+		in       string
+		mod, sub string
+		ok       bool
+	}{
+		{"example.com/m@v1.2.3/f.go", "example.com/m", "f.go", true},
+		{"github.com/!burnt!sushi/toml@v1.0.0/sub/x.go", "github.com/BurntSushi/toml", "sub/x.go", true},
+		{"example.com/m@v1.2.3", "", "", false},
+		{"example.com/m/f.go", "", "", false},
+	}
+	for _, tt := range tests {
+		mod, sub, ok := splitModuleVersion(tt.in)
+		if mod != tt.mod || sub != tt.sub || ok != tt.ok {
+			t.Errorf("splitModuleVersion(%q) = %q, %q, %v, want %q, %q, %v", tt.in, mod, sub, ok, tt.mod, tt.sub, tt.ok)
+		}
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	tmp := t.TempDir()
+	pwd := filepath.Join(tmp, "work")
+	root := filepath.Join(tmp, "root")
+	for _, f := range []string{
+		"work/app/main.go",
+		"work/rel.go",
+		"root/app/server.go",
+		"root/other/server.go",
+		"root/util.go",
+	} {
+		writeFile(t, filepath.Join(tmp, f), "package x\n")
+	}
+	r := &locationResolver{imports: &goImportResolver{}, pwd: pwd, roots: []string{root, pwd}}
+	tests := []struct {
+		file, want string
+	}{
+		{"rel.go", filepath.Join(pwd, "rel.go")},
+		{filepath.Join(pwd, "app/main.go"), filepath.Join(pwd, "app/main.go")},
+		// A checkout elsewhere: the longest suffix that exists.
+		{"/home/ci/src/app/main.go", filepath.Join(pwd, "app/main.go")},
+		{"/build/x/app/server.go", filepath.Join(root, "app/server.go")},
+		// Only the base name exists locally, in an unrelated directory.
+		{"/build/x/server.go", ""},
+		{"/build/x/util.go", ""},
+		// A bare base name is all the location says.
+		{"util.go", filepath.Join(root, "util.go")},
+		{"missing/file.go", ""},
+	}
+	for _, tt := range tests {
+		if got := r.resolve(tt.file); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}